@@ -1,14 +1,28 @@
 package telemetryctl
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	"github.com/elenaochkina/pg-telemetry-lab/internal/benchmark"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/cloud/fake"
 	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
-	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/dockerpg"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/k8s"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
+
+	// Providers register their targets and benchmark runners in init.
+	_ "github.com/elenaochkina/pg-telemetry-lab/internal/provider/cloudpg"
+	_ "github.com/elenaochkina/pg-telemetry-lab/internal/provider/localproc"
+	_ "github.com/elenaochkina/pg-telemetry-lab/internal/provider/podmanpg"
 )
 
 // Run is the entry point for CLI logic. It takes os.Args[1:].
@@ -19,14 +33,21 @@ func Run(args []string) error {
 		return fmt.Errorf("not enough arguments\n\n%s", usage())
 	}
 
-	cmd := args[0]    // provision | destroy | benchmark
-	target := args[1] // local (later maybe cloud)
+	cmd := args[0]    // provision | destroy | benchmark | slots | lag | failover | switchover | scale | stop | start | restart | status | clusters | render | fakecloud
+	target := args[1] // a registered provider target (see usage); "list" for clusters, "k8s" for render, "serve" for fakecloud
 
 	fs := flag.NewFlagSet("telemetryctl", flag.ContinueOnError)
 
 	var configPath string
 	fs.StringVar(&configPath, "config", "config.yaml", "path to config file")
 
+	var cluster string
+	fs.StringVar(&cluster, "cluster", "", "cluster name (default: cluster.name from config, else \"default\")")
+
+	// Provision flags.
+	var recreate bool
+	fs.BoolVar(&recreate, "recreate", false, "recreate drifted nodes even if their data is not on a volume (provision)")
+
 	// Benchmark-related flags.
 	var duration int
 	var clients int
@@ -38,68 +59,217 @@ func Run(args []string) error {
 	fs.IntVar(&scale, "scale", 1, "pgbench scale factor (dataset size)")
 	fs.IntVar(&progress, "progress", 5, "pgbench progress interval in seconds (0 disables progress output)")
 
+	// Failover/switchover flags.
+	var failoverTo string
+	fs.StringVar(&failoverTo, "to", "", "replica container to promote (failover, switchover)")
+
+	// Destroy flags.
+	var keepData, purge bool
+	fs.BoolVar(&keepData, "keep-data", false, "keep persistent data (destroy; default)")
+	fs.BoolVar(&purge, "purge", false, "also remove persistent data: named volumes or data directories (destroy)")
+
+	// Render flags.
+	var outDir string
+	fs.StringVar(&outDir, "out", "k8s", "directory to write manifests to (render)")
+
+	// Fake cloud flags.
+	var listen string
+	var delay time.Duration
+	fs.StringVar(&listen, "listen", "127.0.0.1:8089", "address to serve the fake cloud API on (fakecloud)")
+	fs.DurationVar(&delay, "delay", fake.DefaultDelay, "time each fake instance spends creating or deleting (fakecloud)")
+
+	// Scale flags.
+	var replicas int
+	fs.IntVar(&replicas, "replicas", -1, "desired number of replicas (scale)")
+
+	// Stop/start/restart flags.
+	var nodes string
+	var immediate bool
+	fs.StringVar(&nodes, "node", "", "comma-separated containers to act on (stop, start, restart; default: all)")
+	fs.BoolVar(&immediate, "immediate", false, "kill servers without a shutdown checkpoint, forcing crash recovery (stop, restart)")
+
 	if err := fs.Parse(args[2:]); err != nil {
 		return fmt.Errorf("parsing flags: %w", err)
 	}
 
-	//Load config only for commands that need it
+	// Load config for the commands that need it, and for any other command
+	// given --config explicitly, which then acts on that config's cluster.
+	configSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			configSet = true
+		}
+	})
 	var cfg *config.Config
-	var err error	
-	if cmd == "provision" || cmd == "benchmark" {
+	var err error
+	if configSet || cmd == "provision" || cmd == "benchmark" || cmd == "scale" || cmd == "render" {
 		cfg, err = config.Load(configPath)
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
+		// Namespaces container, network and volume names for the cluster.
+		if err := cfg.UseCluster(cluster); err != nil {
+			return fmt.Errorf("selecting cluster: %w", err)
+		}
+		cluster = cfg.ClusterName()
+	} else if cluster != "" {
+		if err := config.ValidateClusterName(cluster); err != nil {
+			return err
+		}
 	}
 
 	switch cmd {
 	case "provision":
-		return handleProvision(target, cfg)
+		return handleProvision(target, cluster, cfg, recreate)
 
 	case "destroy":
-		return handleDestroy(target)
+		return handleDestroy(target, cluster, keepData, purge)
 
 	case "benchmark":
-		return handleBenchmark(target, cfg, duration, clients, scale, progress)
+		return handleBenchmark(target, cluster, cfg, duration, clients, scale, progress)
+
+	case "slots":
+		return handleSlots(target, cluster)
+
+	case "lag":
+		return handleLag(target, cluster)
+
+	case "failover":
+		return handleFailover(target, cluster, failoverTo)
+
+	case "switchover":
+		return handleSwitchover(target, cluster, failoverTo)
+
+	case "scale":
+		return handleScale(target, cluster, cfg, replicas)
+
+	case "stop", "start", "restart":
+		return handleLifecycle(cmd, target, cluster, nodes, immediate)
+
+	case "status":
+		return handleStatus(target, cluster)
+
+	case "clusters":
+		return handleClusters(target)
+
+	case "render":
+		return handleRender(target, cfg, outDir)
+
+	case "fakecloud":
+		return handleFakeCloud(target, listen, delay)
 
 	default:
 		return fmt.Errorf("unknown command %q\n\n%s", cmd, usage())
 	}
 }
 
-func handleProvision(target string, cfg *config.Config) error {
-	switch target {
-	case "local":
-		provider := dockerpg.NewDockerPostgresProvider()
-		if err := provider.ProvisionPostgres(cfg); err != nil {
-			return fmt.Errorf("provisioning local postgres: %w", err)
+// newCapable returns the provider managing cluster on target, for commands
+// only some targets support. The target must declare capability, and its
+// provider implement the interface T that goes with it.
+func newCapable[T any](target, cluster, capability string) (T, provider.Target, error) {
+	var zero T
+	t, err := provider.Lookup(target, capability)
+	if err != nil {
+		return zero, t, err
+	}
+	p, err := t.New(cluster)
+	if err != nil {
+		return zero, t, err
+	}
+	c, ok := p.(T)
+	if !ok {
+		return zero, t, fmt.Errorf("target %q declares %s but its provider does not implement it", target, capability)
+	}
+	return c, t, nil
+}
+
+func handleProvision(target, cluster string, cfg *config.Config, recreate bool) error {
+	t, err := provider.Lookup(target, provider.CapProvision)
+	if err != nil {
+		return err
+	}
+	p, err := t.New(cluster)
+	if err != nil {
+		return err
+	}
+	if op, ok := p.(provider.OptionsProvisioner); ok {
+		err = op.ProvisionPostgresWithOptions(cfg, provider.ProvisionOptions{Recreate: recreate})
+	} else if recreate {
+		return fmt.Errorf("--recreate is not supported by target %q", target)
+	} else {
+		err = p.ProvisionPostgres(cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("provisioning %s postgres: %w", target, err)
+	}
+	fmt.Printf("✅ %s PostgreSQL cluster provisioned successfully.\n", t.Title)
+	return printEndpoints(p)
+}
+
+func handleDestroy(target, cluster string, keepData, purge bool) error {
+	if keepData && purge {
+		return fmt.Errorf("--keep-data and --purge are mutually exclusive")
+	}
+	t, err := provider.Lookup(target, provider.CapDestroy)
+	if err != nil {
+		return err
+	}
+	var summary string
+	if t.Destroy != nil {
+		summary, err = t.Destroy(cluster, provider.DestroyOptions{Purge: purge})
+	} else {
+		var p provider.PostgresProvider
+		if p, err = t.New(cluster); err == nil {
+			err = p.DestroyPostgres()
 		}
-		fmt.Println("✅ Local PostgreSQL cluster provisioned successfully.")
-		return nil
-	case "cloud":
-		return fmt.Errorf("cloud target not implemented yet")
-	default:
-		return fmt.Errorf("unsupported target %q (only \"local\" is supported for now)", target)
 	}
+	if err != nil {
+		return fmt.Errorf("destroying %s postgres: %w", target, err)
+	}
+	if summary != "" {
+		fmt.Printf("✅ %s PostgreSQL cluster destroyed (%s).\n", t.Title, summary)
+	} else {
+		fmt.Printf("✅ %s PostgreSQL cluster destroyed.\n", t.Title)
+	}
+	return nil
 }
 
-func handleDestroy(target string) error {
-	switch target {
-	case "local":
-		provider := dockerpg.NewDockerPostgresProvider()
-		if err := provider.DestroyPostgres(); err != nil {
-			return fmt.Errorf("destroying local postgres: %w", err)
+// printEndpoints prints where each node of a freshly provisioned cluster
+// accepts connections, inside its network and from this machine.
+func printEndpoints(p provider.PostgresProvider) error {
+	endpoints, err := p.Endpoints(context.Background())
+	if err != nil {
+		return fmt.Errorf("reading endpoints: %w", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tROLE\tINTERNAL\tEXTERNAL")
+	for _, e := range endpoints {
+		external := "-"
+		if e.ExternalPort != 0 {
+			external = fmt.Sprintf("%s:%d", e.ExternalHost, e.ExternalPort)
 		}
-		fmt.Println("✅ Local PostgreSQL cluster destroyed (containers removed).")
-		return nil
-	case "cloud":
-		return fmt.Errorf("cloud target not implemented yet")
-	default:
-		return fmt.Errorf("unsupported target %q (only \"local\" is supported for now)", target)
+		fmt.Fprintf(w, "%s\t%s\t%s:%d\t%s\n", e.Node, e.Role, e.Host, e.Port, external)
+	}
+	return w.Flush()
+}
+
+// handleFakeCloud handles `fakecloud serve`, running the in-memory control
+// plane the cloud target can be pointed at without an account.
+func handleFakeCloud(sub, listen string, delay time.Duration) error {
+	if sub != "serve" {
+		return fmt.Errorf("unknown fakecloud subcommand %q (only \"serve\" is supported)", sub)
+	}
+	server := fake.NewServer()
+	server.Delay = delay
+	server.Token = os.Getenv("CLOUD_API_TOKEN")
+	fmt.Printf("Fake cloud API listening on http://%s (instances take %s to change state)\n", listen, delay)
+	if err := http.ListenAndServe(listen, server); err != nil {
+		return fmt.Errorf("serving fake cloud API: %w", err)
 	}
+	return nil
 }
 
-func handleBenchmark(target string, cfg *config.Config, duration, clients, scale, progress int) error {
+func handleBenchmark(target, cluster string, cfg *config.Config, duration, clients, scale, progress int) error {
 	// Basic sane defaults/validation.
 	if duration <= 0 {
 		duration = 60
@@ -113,43 +283,338 @@ func handleBenchmark(target string, cfg *config.Config, duration, clients, scale
 	if progress < 0 {
 		progress = 0
 	}
-	switch target {
-	case "local":
-		runner := benchmark.NewDockerRunner(
-			cfg.Postgres.Image,
-			cfg.Postgres.Network,
-		)
 
-		opts := benchmark.PgBenchOptions{
-			HostName:     cfg.Postgres.Primary.HostName,
-			Port:     cfg.Postgres.Primary.Port,
-			User:     cfg.Postgres.Primary.User,
-			Database: cfg.Postgres.Primary.Database,
+	t, err := provider.Lookup(target, provider.CapBenchmark)
+	if err != nil {
+		return err
+	}
+	p, err := t.New(cluster)
+	if err != nil {
+		return err
+	}
+	// The runner is registered next to the provider and can reach its
+	// internal endpoints.
+	runner, err := benchmark.NewRunner(target, cfg, cluster)
+	if err != nil {
+		return err
+	}
+
+	// Endpoints follow failovers and refuse a cluster whose last provision
+	// failed.
+	endpoints, err := p.Endpoints(context.Background())
+	if err != nil {
+		return fmt.Errorf("finding the primary: %w", err)
+	}
+	primary, err := provider.PrimaryEndpoint(endpoints)
+	if err != nil {
+		return err
+	}
+
+	opts := benchmark.PgBenchOptions{
+		HostName: primary.Host,
+		Port:     primary.Port,
+		User:     cfg.Postgres.Primary.User,
+		Database: cfg.Postgres.Primary.Database,
 
-			Duration: duration,
-			Clients:  clients,
-			Scale:    scale,
-			Progress: progress,
+		Duration: duration,
+		Clients:  clients,
+		Scale:    scale,
+		Progress: progress,
+	}
+
+	if err := runner.Init(opts); err != nil {
+		return fmt.Errorf("pgbench init failed: %w", err)
+	}
+
+	// Logical subscribers only replicate tables that existed when their
+	// subscription was last refreshed, so pick up the fresh pgbench tables.
+	if syncer, ok := p.(provider.SubscriberSyncer); ok && cfg.ReplicationMode() == config.ReplicationLogical {
+		if err := syncer.SyncSubscribers(); err != nil {
+			return fmt.Errorf("syncing logical subscribers: %w", err)
 		}
+	}
+
+	if err := runner.Run(opts); err != nil {
+		return fmt.Errorf("pgbench run failed: %w", err)
+	}
 
-		if err := runner.Init(opts); err != nil {
-			return fmt.Errorf("pgbench init failed: %w", err)
+	fmt.Println("✅ Benchmark completed successfully.")
+	return nil
+}
+
+func handleSlots(target, cluster string) error {
+	p, _, err := newCapable[provider.SlotLister](target, cluster, provider.CapSlots)
+	if err != nil {
+		return err
+	}
+	slots, err := p.ListSlots()
+	if err != nil {
+		return fmt.Errorf("listing replication slots: %w", err)
+	}
+	if len(slots) == 0 {
+		fmt.Println("No replication slots on the primary.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SLOT\tTYPE\tACTIVE\tRESTART_LSN\tRETAINED_WAL")
+	for _, slot := range slots {
+		restart := slot.RestartLSN
+		if restart == "" {
+			restart = "-"
 		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\n",
+			slot.Name, slot.Type, slot.Active, restart, util.FormatBytes(slot.RetainedBytes))
+	}
+	return w.Flush()
+}
 
-		if err := runner.Run(opts); err != nil {
-			return fmt.Errorf("pgbench run failed: %w", err)
+func handleLag(target, cluster string) error {
+	p, _, err := newCapable[provider.LagReporter](target, cluster, provider.CapLag)
+	if err != nil {
+		return err
+	}
+	lags, err := p.ReplicationLag()
+	if err != nil {
+		return fmt.Errorf("reading replication lag: %w", err)
+	}
+	if len(lags) == 0 {
+		fmt.Println("No replicas in the cluster.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPLICA\tUPSTREAM\tAPPLY_DELAY\tRECEIVED_LSN\tREPLAYED_LSN\tNOT_RECEIVED\tNOT_REPLAYED\tREPLAY_AGE")
+	for _, lag := range lags {
+		delay, received, age := lag.ApplyDelay, lag.ReceivedLSN, "-"
+		if delay == "" {
+			delay = "-"
+		}
+		if received == "" {
+			received = "-"
+		}
+		if lag.ReplayAge >= 0 {
+			age = lag.ReplayAge.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			lag.Name, lag.Upstream, delay, received, lag.ReplayedLSN,
+			util.FormatBytes(lag.ReceiveLagBytes), util.FormatBytes(lag.ReplayLagBytes), age)
+	}
+	return w.Flush()
+}
+
+func handleFailover(target, cluster, to string) error {
+	if to == "" {
+		return fmt.Errorf("--to is required (name of the replica to promote)")
+	}
+	p, _, err := newCapable[provider.Failoverer](target, cluster, provider.CapFailover)
+	if err != nil {
+		return err
+	}
+	if err := p.Failover(to); err != nil {
+		return fmt.Errorf("failing over %s postgres: %w", target, err)
+	}
+	fmt.Printf("✅ Failover complete, %s is the new primary.\n", to)
+	return nil
+}
+
+func handleSwitchover(target, cluster, to string) error {
+	if to == "" {
+		return fmt.Errorf("--to is required (name of the replica to promote)")
+	}
+	p, _, err := newCapable[provider.Switchoverer](target, cluster, provider.CapSwitchover)
+	if err != nil {
+		return err
+	}
+	if err := p.Switchover(to); err != nil {
+		return fmt.Errorf("switching over %s postgres: %w", target, err)
+	}
+	fmt.Printf("✅ Switchover complete, %s is the new primary.\n", to)
+	return nil
+}
+
+func handleStatus(target, cluster string) error {
+	p, _, err := newCapable[provider.PostgresProvider](target, cluster, provider.CapStatus)
+	if err != nil {
+		return err
+	}
+	return printStatus(p)
+}
+
+// printStatus prints the nodes of any provider with the address each is
+// reachable on from this machine.
+func printStatus(p provider.PostgresProvider) error {
+	ctx := context.Background()
+	status, err := p.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("reading cluster status: %w", err)
+	}
+	// Endpoints refuses a failed cluster; the table then just lacks them.
+	external := make(map[string]string)
+	if endpoints, err := p.Endpoints(ctx); err == nil {
+		for _, e := range endpoints {
+			if e.ExternalPort != 0 {
+				external[e.Node] = fmt.Sprintf("%s:%d", e.ExternalHost, e.ExternalPort)
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tROLE\tSTATE\tENDPOINT")
+	for _, n := range status.Nodes {
+		endpoint, ok := external[n.Name]
+		if !ok {
+			endpoint = "-"
 		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", n.Name, n.Role, n.State, endpoint)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nCluster: %s\n", status.Cluster)
+	if status.Error != "" {
+		fmt.Printf("The last provision failed: %s\n", status.Error)
+	}
+	return nil
+}
 
-		fmt.Println("✅ Benchmark completed successfully.")
+// handleClusters handles `clusters list`, which covers every cluster
+// rather than one target.
+func handleClusters(sub string) error {
+	if sub != "list" {
+		return fmt.Errorf("unknown clusters subcommand %q (only \"list\" is supported)", sub)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tCLUSTER\tPRIMARY\tRUNNING\tSTATE_FILE")
+	found := false
+	for _, t := range provider.Targets() {
+		if !t.Supports(provider.CapClusters) {
+			continue
+		}
+		lister, _, err := newCapable[provider.ClusterLister](t.Name, "", provider.CapClusters)
+		if err != nil {
+			return err
+		}
+		clusters, err := lister.ListClusters()
+		if err != nil {
+			return fmt.Errorf("listing %s clusters: %w", t.Name, err)
+		}
+		for _, c := range clusters {
+			primary, stateFile := c.Primary, c.StateFile
+			if primary == "" {
+				primary = "-"
+			}
+			if stateFile == "" {
+				stateFile = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d/%d\t%s\n", t.Name, c.Name, primary, c.Running, c.Containers, stateFile)
+			found = true
+		}
+	}
+	if !found {
+		fmt.Println("No clusters found.")
 		return nil
+	}
+	return w.Flush()
+}
 
-	case "cloud":
-		return fmt.Errorf("benchmark target %q not implemented yet", target)
+// handleRender handles `render k8s`, which writes manifests instead of
+// provisioning anything.
+func handleRender(target string, cfg *config.Config, outDir string) error {
+	switch target {
+	case "k8s":
+		if err := k8s.NewManifestProvider(outDir).ProvisionPostgres(cfg); err != nil {
+			return fmt.Errorf("rendering kubernetes manifests: %w", err)
+		}
+		secret := cfg.Kubernetes.SecretName
+		if secret == "" {
+			secret = cfg.ClusterName() + "-postgres"
+		}
+		fmt.Printf("✅ Kubernetes manifests written to %s.\n", outDir)
+		fmt.Println("Create the password secret, then apply them:")
+		fmt.Printf("  kubectl create secret generic %s --from-literal=PG_PASSWORD=...\n", secret)
+		fmt.Printf("  kubectl apply -f %s\n", outDir)
+		return nil
 	default:
-		return fmt.Errorf("unsupported target %q (only \"local\" is supported for now)", target)
+		return fmt.Errorf("unsupported render target %q (only \"k8s\" is supported)", target)
 	}
 }
 
+// handleLifecycle handles stop, start and restart of some or all nodes,
+// keeping their data. Starts report how long each node took to recover.
+func handleLifecycle(cmd, target, cluster, nodes string, immediate bool) error {
+	var names []string
+	if nodes != "" {
+		names = strings.Split(nodes, ",")
+	}
+	if cmd == "start" && immediate {
+		return fmt.Errorf("--immediate only applies to stop and restart")
+	}
+	p, _, err := newCapable[provider.NodeRestarter](target, cluster, provider.CapRestart)
+	if err != nil {
+		return err
+	}
+	opts := provider.StopOptions{Immediate: immediate}
+	var recovery []provider.NodeRecovery
+	switch cmd {
+	case "stop":
+		if err := p.StopNodes(names, opts); err != nil {
+			return fmt.Errorf("stopping %s postgres: %w", target, err)
+		}
+		fmt.Println("✅ Stopped, data kept.")
+		return nil
+	case "start":
+		recovery, err = p.StartNodes(names)
+	default:
+		recovery, err = p.RestartNodes(names, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("%sing %s postgres: %w", cmd, target, err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tROLE\tREADY\tSTREAMING")
+	for _, r := range recovery {
+		ready, streaming := "-", "-"
+		if r.Ready != 0 {
+			ready = r.Ready.Round(time.Millisecond).String()
+		}
+		if r.Streaming != 0 {
+			streaming = r.Streaming.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.Role, ready, streaming)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println("✅ Cluster is back up.")
+	return nil
+}
+
+func handleScale(target, cluster string, cfg *config.Config, replicas int) error {
+	if replicas < 0 {
+		return fmt.Errorf("--replicas is required (desired number of replicas)")
+	}
+	p, t, err := newCapable[provider.Scaler](target, cluster, provider.CapScale)
+	if err != nil {
+		return err
+	}
+	if err := p.ScaleReplicas(cfg, replicas); err != nil {
+		return fmt.Errorf("scaling %s postgres: %w", target, err)
+	}
+	fmt.Printf("✅ %s PostgreSQL cluster scaled to %d replicas.\n", t.Title, replicas)
+	return nil
+}
+
+// targetsUsage lists the registered targets with their capabilities.
+func targetsUsage() string {
+	var b strings.Builder
+	for _, t := range provider.Targets() {
+		fmt.Fprintf(&b, "  %-11s %s (%s)\n", t.Name, t.Description, strings.Join(t.Capabilities, ", "))
+	}
+	return b.String()
+}
+
 // usage returns the usage string instead of printing+os.Exit.
 func usage() string {
 	return `Usage:
@@ -159,20 +624,64 @@ Commands:
   provision   Provision PostgreSQL resources
   destroy     Destroy PostgreSQL resources
   benchmark   Run pgbench benchmark against PostgreSQL
+  slots       List replication slots on the primary with retained WAL
+  lag         Show received vs replayed LSN and lag per replica
+  failover    Fence the primary and promote a replica (--to <replica>)
+  switchover  Promote a replica and rejoin the old primary as a standby (--to <replica>)
+  scale       Add or remove replicas on a running cluster (--replicas <n>)
+  stop        Stop nodes, keeping their data (--node <name,...>, default all)
+  start       Start stopped nodes and report their recovery time (--node <name,...>)
+  restart     Stop and start nodes, reporting recovery and reconnection time (--node, --immediate)
+  status      Show the cluster's nodes, their live roles and state (local: containers found by label)
+  clusters    List local clusters (clusters list)
+  render      Write Kubernetes manifests for the configured topology (render k8s --out <dir>)
+  fakecloud   Serve a local fake of the cloud control plane (fakecloud serve --listen <addr>)
 
 Targets:
-  local       Use local Docker-based PostgreSQL
-
+` + targetsUsage() + `
 Flags:
-  --config      Path to YAML config file (default: config.yaml)
+  --config      Path to YAML config file (default: config.yaml; other commands use its cluster.name when given)
+  --cluster     Cluster name (default: cluster.name from config, else "default")
+  --recreate    Recreate drifted nodes even if their data is not on a volume (provision)
   --duration    Benchmark duration in seconds (benchmark)
   --clients     Number of concurrent clients (benchmark)
   --scale       pgbench scale factor (benchmark)
   --progress    pgbench progress interval in seconds (benchmark)
+  --to          Replica container to promote (failover, switchover)
+  --replicas    Desired number of replicas (scale)
+  --node        Comma-separated containers to act on (stop, start, restart; default: all)
+  --immediate   Kill servers without a shutdown checkpoint, forcing crash recovery (stop, restart)
+  --keep-data   Keep persistent data (destroy, default)
+  --purge       Also remove persistent data: named volumes or data directories (destroy)
+  --out         Directory to write manifests to (render, default: k8s)
+  --listen      Address to serve the fake cloud API on (fakecloud, default: 127.0.0.1:8089)
+  --delay       Time fake instances spend creating or deleting (fakecloud, default: 2s)
 
 Examples:
   telemetryctl provision local --config config.example.yaml
   telemetryctl benchmark local --config config.example.yaml --duration 60 --clients 20 --scale 1 --progress 5
+  telemetryctl slots     local
+  telemetryctl lag       local
+  telemetryctl status    local
+  telemetryctl provision local --config pg15.yaml --cluster pg15
+  telemetryctl clusters  list
+  telemetryctl destroy   local --cluster pg15
+  telemetryctl failover  local --to pg-replica-2
+  telemetryctl switchover local --to pg-replica-1
+  telemetryctl scale     local --config config.example.yaml --replicas 3
+  telemetryctl restart   local --node pg-primary --immediate
+  telemetryctl stop      local --node pg-replica-2
+  telemetryctl start     local
   telemetryctl destroy   local --config config.example.yaml
+  telemetryctl destroy   local --purge
+  telemetryctl provision podman --config config.example.yaml
+  telemetryctl benchmark podman --config config.example.yaml --duration 60
+  telemetryctl destroy   podman
+  telemetryctl provision localproc --config config.example.yaml
+  telemetryctl destroy   localproc --purge
+  telemetryctl render    k8s --config config.example.yaml --out deploy/k8s
+  telemetryctl fakecloud serve --listen 127.0.0.1:8089
+  telemetryctl provision cloud --config config.example.yaml
+  telemetryctl destroy   cloud
 `
 }
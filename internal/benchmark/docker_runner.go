@@ -1,23 +1,29 @@
 package benchmark
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"os/exec"
 	"strconv"
 
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/docker"
 	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
 )
 
 // DockerRunner implements the Runner interface using Docker.
 type DockerRunner struct {
+	Client  *docker.Client
 	Image   string
 	Network string
+
+	// Limits caps the pgbench container's CPU and memory (zero: unlimited).
+	Limits config.ResourceLimits
 }
 
 // NewDockerRunner creates a Docker-based pgbench runner.
-func NewDockerRunner(image, network string) *DockerRunner {
+func NewDockerRunner(client *docker.Client, image, network string) *DockerRunner {
 	return &DockerRunner{
+		Client:  client,
 		Image:   image,
 		Network: network,
 	}
@@ -27,15 +33,7 @@ func NewDockerRunner(image, network string) *DockerRunner {
 func (r *DockerRunner) Init(opts PgBenchOptions) error {
 	fmt.Println("🔧 Initializing pgbench schema...")
 
-	// Flags specific to initialization.
-	pgbenchArgs := []string{
-		"-i",
-		"-s", strconv.Itoa(opts.Scale),
-	}
-	// Shared connection args.
-	pgbenchArgs = append(pgbenchArgs, buildConnArgs(opts)...)
-
-	output, err := r.runPgbench(pgbenchArgs)
+	output, err := r.runPgbench(initArgs(opts))
 
 	// For now, just print the raw pgbench output.
 	// Later parse this to extract TPS, latency, etc.
@@ -53,19 +51,7 @@ func (r *DockerRunner) Init(opts PgBenchOptions) error {
 func (r *DockerRunner) Run(opts PgBenchOptions) error {
 	fmt.Println("🚀 Running pgbench benchmark...")
 
-	// Flags specific to running the workload.
-	pgbenchArgs := []string{
-		"-T", strconv.Itoa(opts.Duration),
-		"-c", strconv.Itoa(opts.Clients),
-	}
-	if opts.Progress > 0 {
-		pgbenchArgs = append(pgbenchArgs, "-P", strconv.Itoa(opts.Progress))
-	}
-
-	// Shared connection args.
-	pgbenchArgs = append(pgbenchArgs, buildConnArgs(opts)...)
-
-	output, err := r.runPgbench(pgbenchArgs)
+	output, err := r.runPgbench(runArgs(opts))
 	fmt.Print(output)
 
 	if err != nil {
@@ -79,42 +65,71 @@ func (r *DockerRunner) Run(opts PgBenchOptions) error {
 // runPgbench runs a pgbench command inside a Docker container on the configured network.
 // It returns the combined pgbench output (stdout + stderr) and an error, if any.
 func (r *DockerRunner) runPgbench(pgbenchArgs []string) (string, error) {
+	ctx := context.Background()
+
 	// Get password from environment (host-side).
 	pw, err := util.GetRequiredEnv("PG_PASSWORD")
 	if err != nil {
-		return "",  err
+		return "", err
 	}
 
-	// Build the full docker command arguments.
-	dockerArgs := []string{
-		"run",
-		"--rm",
-		"--network", r.Network,
-		"-e", "PGPASSWORD=" + pw, // inside container, pgbench reads PGPASSWORD
-		"--entrypoint", "pgbench", // override default entrypoint
-		r.Image,
+	if err := r.Client.EnsureImage(ctx, r.Image); err != nil {
+		return "", fmt.Errorf("ensuring image %q: %w", r.Image, err)
 	}
-	dockerArgs = append(dockerArgs, pgbenchArgs...)
-
-	// Mask sensitive env vars for printing.
-	printArgs := util.MaskArgs(dockerArgs)
 
-	fmt.Printf("Executing: docker %s\n", util.FormatArgs(printArgs))
+	cfg := docker.ContainerConfig{
+		Image:      r.Image,
+		Entrypoint: []string{"pgbench"}, // override default entrypoint
+		Cmd:        pgbenchArgs,
+		Env:        []string{"PGPASSWORD=" + pw}, // inside container, pgbench reads PGPASSWORD
+		HostConfig: docker.HostConfig{
+			NetworkMode: r.Network,
+			ShmSize:     r.Limits.ShmSizeBytes,
+			Resources: docker.Resources{
+				NanoCPUs: r.Limits.NanoCPUs,
+				Memory:   r.Limits.MemoryBytes,
+			},
+		},
+	}
 
- 	// Capture pgbench output (both stdout and stderr).
-	var out bytes.Buffer
-	
-	cmd := exec.Command("docker", dockerArgs...)
-	cmd.Stdout = &out
-	cmd.Stderr = &out // pgbench prints progress to stderr
+	fmt.Printf("Executing: pgbench %s(image %s, network %s)\n", util.FormatArgs(pgbenchArgs), r.Image, r.Network)
 
-	if err := cmd.Run(); err != nil {
-		// Return whatever pgbench printed, plus a wrapped error.
-		return out.String(), fmt.Errorf("running pgbench: %w", err)
+	// The container is removed after pgbench exits; its output (progress
+	// goes to stderr) is collected from the logs first.
+	res, err := r.Client.Run(ctx, "", cfg)
+	if err != nil {
+		return "", fmt.Errorf("running pgbench container: %w", err)
+	}
+	if res.ExitCode != 0 {
+		// Return whatever pgbench printed, plus an error.
+		return res.Output, fmt.Errorf("pgbench exited with code %d", res.ExitCode)
 	}
+	return res.Output, nil
+}
 
-	return out.String(), err
+// initArgs builds the pgbench arguments of the initialization phase.
+func initArgs(opts PgBenchOptions) []string {
+	// Flags specific to initialization.
+	pgbenchArgs := []string{
+		"-i",
+		"-s", strconv.Itoa(opts.Scale),
+	}
+	// Shared connection args.
+	return append(pgbenchArgs, buildConnArgs(opts)...)
+}
 
+// runArgs builds the pgbench arguments of the workload phase.
+func runArgs(opts PgBenchOptions) []string {
+	// Flags specific to running the workload.
+	pgbenchArgs := []string{
+		"-T", strconv.Itoa(opts.Duration),
+		"-c", strconv.Itoa(opts.Clients),
+	}
+	if opts.Progress > 0 {
+		pgbenchArgs = append(pgbenchArgs, "-P", strconv.Itoa(opts.Progress))
+	}
+	// Shared connection args.
+	return append(pgbenchArgs, buildConnArgs(opts)...)
 }
 
 // buildConnArgs builds the common pgbench connection arguments.
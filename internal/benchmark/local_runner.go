@@ -0,0 +1,85 @@
+package benchmark
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
+)
+
+// LocalRunner implements the Runner interface by running pgbench directly
+// on the host, with no container in between.
+type LocalRunner struct {
+	// BinDir is the directory holding pgbench; empty searches PATH.
+	BinDir string
+}
+
+// NewLocalRunner creates a host-process pgbench runner.
+func NewLocalRunner(binDir string) *LocalRunner {
+	return &LocalRunner{BinDir: binDir}
+}
+
+// Init prepares the database for benchmarking by running `pgbench -i`.
+func (r *LocalRunner) Init(opts PgBenchOptions) error {
+	fmt.Println("🔧 Initializing pgbench schema...")
+
+	output, err := r.runPgbench(initArgs(opts))
+	fmt.Print(output)
+
+	if err != nil {
+		return fmt.Errorf("pgbench initialization failed: %w", err)
+	}
+
+	fmt.Println("✅ Initialization complete.")
+	return nil
+}
+
+// Run executes the actual benchmark workload.
+func (r *LocalRunner) Run(opts PgBenchOptions) error {
+	fmt.Println("🚀 Running pgbench benchmark...")
+
+	output, err := r.runPgbench(runArgs(opts))
+	fmt.Print(output)
+
+	if err != nil {
+		return fmt.Errorf("pgbench run failed: %w", err)
+	}
+
+	fmt.Println("✅ Benchmark run complete.")
+	return nil
+}
+
+// runPgbench runs pgbench on the host and returns its combined output.
+func (r *LocalRunner) runPgbench(pgbenchArgs []string) (string, error) {
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return "", err
+	}
+
+	bin := "pgbench"
+	if r.BinDir != "" {
+		bin = filepath.Join(r.BinDir, bin)
+	}
+	fmt.Printf("Executing: %s %s\n", bin, util.FormatArgs(pgbenchArgs))
+
+	cmd := exec.CommandContext(context.Background(), bin, pgbenchArgs...)
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+pw)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out.String(), fmt.Errorf("pgbench exited with code %d", exitErr.ExitCode())
+	}
+	if err != nil {
+		return out.String(), fmt.Errorf("running pgbench: %w", err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,94 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/podman"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
+)
+
+// PodmanRunner implements the Runner interface using Podman. pgbench runs
+// in the cluster's pod, so it reaches the servers on 127.0.0.1.
+type PodmanRunner struct {
+	Client *podman.Client
+	Image  string
+	Pod    string
+
+	// Limits caps the pgbench container's CPU and memory (zero: unlimited).
+	// The pod sets the shared memory size.
+	Limits config.ResourceLimits
+}
+
+// NewPodmanRunner creates a Podman-based pgbench runner.
+func NewPodmanRunner(client *podman.Client, image, pod string) *PodmanRunner {
+	return &PodmanRunner{
+		Client: client,
+		Image:  image,
+		Pod:    pod,
+	}
+}
+
+// Init prepares the database for benchmarking by running `pgbench -i`.
+func (r *PodmanRunner) Init(opts PgBenchOptions) error {
+	fmt.Println("🔧 Initializing pgbench schema...")
+
+	output, err := r.runPgbench(initArgs(opts))
+	fmt.Print(output)
+
+	if err != nil {
+		return fmt.Errorf("pgbench initialization failed: %w", err)
+	}
+
+	fmt.Println("✅ Initialization complete.")
+	return nil
+}
+
+// Run executes the actual benchmark workload.
+func (r *PodmanRunner) Run(opts PgBenchOptions) error {
+	fmt.Println("🚀 Running pgbench benchmark...")
+
+	output, err := r.runPgbench(runArgs(opts))
+	fmt.Print(output)
+
+	if err != nil {
+		return fmt.Errorf("pgbench run failed: %w", err)
+	}
+
+	fmt.Println("✅ Benchmark run complete.")
+	return nil
+}
+
+// runPgbench runs a pgbench command in a throwaway container in the pod and
+// returns its combined output.
+func (r *PodmanRunner) runPgbench(pgbenchArgs []string) (string, error) {
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return "", err
+	}
+
+	// A bare --env PGPASSWORD copies the value from the podman process, so
+	// the password stays off the command line.
+	args := []string{"run", "--rm", "--pod", r.Pod, "--env", "PGPASSWORD", "--entrypoint", "pgbench"}
+	if r.Limits.NanoCPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(float64(r.Limits.NanoCPUs)/1e9, 'f', -1, 64))
+	}
+	if r.Limits.MemoryBytes > 0 {
+		args = append(args, "--memory", strconv.FormatInt(r.Limits.MemoryBytes, 10))
+	}
+	args = append(args, r.Image)
+	args = append(args, pgbenchArgs...)
+
+	fmt.Printf("Executing: pgbench %s(image %s, pod %s)\n", util.FormatArgs(pgbenchArgs), r.Image, r.Pod)
+
+	output, err := r.Client.Run(context.Background(), []string{"PGPASSWORD=" + pw}, args...)
+	if err != nil {
+		if code := podman.ExitCode(err); code >= 0 {
+			return output, fmt.Errorf("pgbench exited with code %d", code)
+		}
+		return output, fmt.Errorf("running pgbench container: %w", err)
+	}
+	return output, nil
+}
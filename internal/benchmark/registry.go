@@ -0,0 +1,40 @@
+package benchmark
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+)
+
+// RunnerFactory returns a runner for target's cluster that can reach the
+// cluster's internal endpoints (see provider.Endpoint).
+type RunnerFactory func(cfg *config.Config, cluster string) (Runner, error)
+
+var (
+	runnersMu sync.RWMutex
+	runners   = make(map[string]RunnerFactory)
+)
+
+// RegisterRunner makes a runner factory available for target. Provider
+// packages call it from init, next to registering their target;
+// registering a target twice panics.
+func RegisterRunner(target string, factory RunnerFactory) {
+	runnersMu.Lock()
+	defer runnersMu.Unlock()
+	if _, dup := runners[target]; dup {
+		panic("benchmark: runner for " + target + " registered twice")
+	}
+	runners[target] = factory
+}
+
+// NewRunner returns a runner for the cluster of target.
+func NewRunner(target string, cfg *config.Config, cluster string) (Runner, error) {
+	runnersMu.RLock()
+	factory, ok := runners[target]
+	runnersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no benchmark runner registered for target %q", target)
+	}
+	return factory(cfg, cluster)
+}
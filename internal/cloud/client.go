@@ -0,0 +1,216 @@
+// Package cloud is a client for the managed-Postgres style HTTP API the
+// cloud target talks to: create an instance, create a read replica of it,
+// poll status, read the endpoint and delete. The fake subpackage serves
+// the same API locally.
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client is a managed-Postgres API client.
+type Client struct {
+	http    *http.Client
+	baseURL string
+	token   string
+}
+
+// NewClient returns a client for the API at baseURL, authenticating with
+// token as a bearer token (empty: no Authorization header).
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		http:    &http.Client{Timeout: 30 * time.Second},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+	}
+}
+
+// APIError is returned for any non-2xx response.
+type APIError struct {
+	StatusCode int
+	Method     string
+	Path       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("cloud API %s %s: %d: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 (no such instance).
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409, e.g. an instance name that is
+// already taken or a source that is not available yet.
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// CreateInstance starts creating a primary instance.
+func (c *Client) CreateInstance(ctx context.Context, req CreateInstanceRequest) (*Instance, error) {
+	var inst Instance
+	if err := c.doJSON(ctx, "POST", "/v1/instances", nil, req, &inst); err != nil {
+		return nil, err
+	}
+	return &inst, nil
+}
+
+// CreateReadReplica starts creating a read replica of source, which may
+// itself be a replica.
+func (c *Client) CreateReadReplica(ctx context.Context, source string, req CreateReplicaRequest) (*Instance, error) {
+	var inst Instance
+	if err := c.doJSON(ctx, "POST", "/v1/instances/"+url.PathEscape(source)+"/replicas", nil, req, &inst); err != nil {
+		return nil, err
+	}
+	return &inst, nil
+}
+
+// GetInstance returns an instance by name.
+func (c *Client) GetInstance(ctx context.Context, name string) (*Instance, error) {
+	var inst Instance
+	if err := c.doJSON(ctx, "GET", "/v1/instances/"+url.PathEscape(name), nil, nil, &inst); err != nil {
+		return nil, err
+	}
+	return &inst, nil
+}
+
+// ListInstances returns the instances carrying every one of labels
+// ("key=value").
+func (c *Client) ListInstances(ctx context.Context, labels ...string) ([]Instance, error) {
+	query := url.Values{}
+	for _, l := range labels {
+		query.Add("label", l)
+	}
+	var resp struct {
+		Instances []Instance `json:"instances"`
+	}
+	if err := c.doJSON(ctx, "GET", "/v1/instances", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Instances, nil
+}
+
+// DeleteInstance starts deleting an instance. An instance with replicas
+// cannot be deleted.
+func (c *Client) DeleteInstance(ctx context.Context, name string) error {
+	return c.doJSON(ctx, "DELETE", "/v1/instances/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// WaitAvailable polls an instance every interval until it is available,
+// failing if it reaches the failed status or timeout expires.
+func (c *Client) WaitAvailable(ctx context.Context, name string, interval, timeout time.Duration) (*Instance, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		inst, err := c.GetInstance(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		switch inst.Status {
+		case StatusAvailable:
+			return inst, nil
+		case StatusFailed:
+			return nil, fmt.Errorf("instance %q failed: %s", name, inst.StatusMessage)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("instance %q not available after %s (status %s)", name, timeout, inst.Status)
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// WaitDeleted polls an instance every interval until the API no longer
+// knows it, or timeout expires.
+func (c *Client) WaitDeleted(ctx context.Context, name string, interval, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		inst, err := c.GetInstance(ctx, name)
+		if IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("instance %q still present after %s (status %s)", name, timeout, inst.Status)
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// doJSON sends a request and decodes a JSON response into out (if non-nil).
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding %s %s request: %w", method, path, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloud API %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e ErrorResponse
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &e) == nil && e.Message != "" {
+			msg = e.Message
+		}
+		return &APIError{StatusCode: resp.StatusCode, Method: method, Path: path, Message: msg}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s %s response: %w", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+// Package fake is an in-memory implementation of the managed-Postgres API
+// in package cloud. Instances go through the same creating, available and
+// deleting states as a real control plane, on a configurable delay, but no
+// servers are started: endpoints are made-up host names. It lets the cloud
+// provider be exercised end to end without an account.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/cloud"
+)
+
+// DefaultDelay is how long instances take to create or delete when
+// Server.Delay is not set.
+const DefaultDelay = 2 * time.Second
+
+// namePattern matches instance names the fake accepts, like most managed
+// services: lowercase letters, digits and hyphens.
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,62}$`)
+
+// instance is an instance plus the times its pending transition completes.
+type instance struct {
+	cloud.Instance
+	readyAt   time.Time
+	deletedAt time.Time // zero unless deleting
+}
+
+// Server serves the API. The zero value is not usable; use NewServer.
+type Server struct {
+	// Delay is how long creating and deleting take.
+	Delay time.Duration
+	// Token, if set, must be sent as a bearer token.
+	Token string
+	// Now returns the current time; it can be replaced to step through
+	// transitions without sleeping.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	instances map[string]*instance
+	mux       *http.ServeMux
+}
+
+// NewServer returns an empty fake control plane.
+func NewServer() *Server {
+	s := &Server{
+		Delay:     DefaultDelay,
+		Now:       time.Now,
+		instances: make(map[string]*instance),
+		mux:       http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /v1/instances", s.createInstance)
+	s.mux.HandleFunc("GET /v1/instances", s.listInstances)
+	s.mux.HandleFunc("GET /v1/instances/{name}", s.getInstance)
+	s.mux.HandleFunc("DELETE /v1/instances/{name}", s.deleteInstance)
+	s.mux.HandleFunc("POST /v1/instances/{name}/replicas", s.createReplica)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance()
+	s.mux.ServeHTTP(w, r)
+}
+
+// advance completes the transitions whose time has come.
+func (s *Server) advance() {
+	now := s.Now()
+	for name, inst := range s.instances {
+		switch {
+		case inst.Status == cloud.StatusDeleting && !now.Before(inst.deletedAt):
+			delete(s.instances, name)
+		case inst.Status == cloud.StatusCreating && !now.Before(inst.readyAt):
+			inst.Status = cloud.StatusAvailable
+			inst.Endpoint = &cloud.Endpoint{Host: endpointHost(inst.Name, inst.Region), Port: 5432}
+		}
+	}
+}
+
+// endpointHost makes up a DNS name for an instance.
+func endpointHost(name, region string) string {
+	if region == "" {
+		region = "local"
+	}
+	return fmt.Sprintf("%s.%s.fake-cloud.internal", name, region)
+}
+
+func (s *Server) createInstance(w http.ResponseWriter, r *http.Request) {
+	var req cloud.CreateInstanceRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Database == "" || req.MasterUser == "" || req.MasterPassword == "" {
+		writeError(w, http.StatusBadRequest, "database, master_user and master_password are required")
+		return
+	}
+	inst, ok := s.add(w, req.Name, cloud.Instance{
+		Role:          cloud.RolePrimary,
+		Region:        req.Region,
+		InstanceClass: req.InstanceClass,
+		EngineVersion: req.EngineVersion,
+		Database:      req.Database,
+		Labels:        req.Labels,
+	})
+	if ok {
+		writeJSON(w, http.StatusAccepted, inst)
+	}
+}
+
+func (s *Server) createReplica(w http.ResponseWriter, r *http.Request) {
+	source, ok := s.instances[r.PathValue("name")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("instance %q not found", r.PathValue("name")))
+		return
+	}
+	if source.Status != cloud.StatusAvailable {
+		writeError(w, http.StatusConflict, fmt.Sprintf("source instance %q is %s, not available", source.Name, source.Status))
+		return
+	}
+	var req cloud.CreateReplicaRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	region := req.Region
+	if region == "" {
+		region = source.Region
+	}
+	class := req.InstanceClass
+	if class == "" {
+		class = source.InstanceClass
+	}
+	inst, ok := s.add(w, req.Name, cloud.Instance{
+		Role:          cloud.RoleReplica,
+		Source:        source.Name,
+		Region:        region,
+		InstanceClass: class,
+		EngineVersion: source.EngineVersion,
+		Database:      source.Database,
+		Labels:        req.Labels,
+	})
+	if ok {
+		writeJSON(w, http.StatusAccepted, inst)
+	}
+}
+
+// add registers a new instance in the creating state, writing an error
+// response if the name is invalid or taken.
+func (s *Server) add(w http.ResponseWriter, name string, inst cloud.Instance) (cloud.Instance, bool) {
+	if !namePattern.MatchString(name) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid instance name %q", name))
+		return inst, false
+	}
+	if _, exists := s.instances[name]; exists {
+		writeError(w, http.StatusConflict, fmt.Sprintf("instance %q already exists", name))
+		return inst, false
+	}
+	now := s.Now()
+	inst.Name = name
+	inst.Status = cloud.StatusCreating
+	inst.CreatedAt = now
+	s.instances[name] = &instance{Instance: inst, readyAt: now.Add(s.Delay)}
+	return inst, true
+}
+
+func (s *Server) getInstance(w http.ResponseWriter, r *http.Request) {
+	inst, ok := s.instances[r.PathValue("name")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("instance %q not found", r.PathValue("name")))
+		return
+	}
+	writeJSON(w, http.StatusOK, inst.Instance)
+}
+
+func (s *Server) listInstances(w http.ResponseWriter, r *http.Request) {
+	selectors := r.URL.Query()["label"]
+	list := []cloud.Instance{}
+	for _, name := range slices.Sorted(maps.Keys(s.instances)) {
+		inst := s.instances[name]
+		if matches(inst.Labels, selectors) {
+			list = append(list, inst.Instance)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string][]cloud.Instance{"instances": list})
+}
+
+// matches reports whether labels carry every key=value selector.
+func matches(labels map[string]string, selectors []string) bool {
+	for _, sel := range selectors {
+		key, value, _ := strings.Cut(sel, "=")
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) deleteInstance(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	inst, ok := s.instances[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("instance %q not found", name))
+		return
+	}
+	for _, other := range s.instances {
+		if other.Source == name {
+			writeError(w, http.StatusConflict, fmt.Sprintf("instance %q has read replica %q; delete it first", name, other.Name))
+			return
+		}
+	}
+	if inst.Status != cloud.StatusDeleting {
+		inst.Status = cloud.StatusDeleting
+		inst.Endpoint = nil
+		inst.deletedAt = s.Now().Add(s.Delay)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// decode reads a JSON request body, writing a 400 response on failure.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, cloud.ErrorResponse{Message: msg})
+}
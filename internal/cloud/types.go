@@ -0,0 +1,69 @@
+package cloud
+
+import "time"
+
+// Instance statuses reported by the API.
+const (
+	StatusCreating  = "creating"
+	StatusAvailable = "available"
+	StatusDeleting  = "deleting"
+	StatusFailed    = "failed"
+)
+
+// Instance roles.
+const (
+	RolePrimary = "primary"
+	RoleReplica = "replica"
+)
+
+// CreateInstanceRequest is the body of POST /v1/instances.
+type CreateInstanceRequest struct {
+	Name           string            `json:"name"`
+	Region         string            `json:"region,omitempty"`
+	InstanceClass  string            `json:"instance_class,omitempty"`
+	EngineVersion  string            `json:"engine_version,omitempty"`
+	StorageGB      int               `json:"storage_gb,omitempty"`
+	Database       string            `json:"database"`
+	MasterUser     string            `json:"master_user"`
+	MasterPassword string            `json:"master_password"`
+	Parameters     map[string]string `json:"parameters,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+}
+
+// CreateReplicaRequest is the body of POST /v1/instances/{source}/replicas.
+// The replica inherits the engine, database and credentials of its source.
+type CreateReplicaRequest struct {
+	Name          string            `json:"name"`
+	Region        string            `json:"region,omitempty"`
+	InstanceClass string            `json:"instance_class,omitempty"`
+	Parameters    map[string]string `json:"parameters,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+}
+
+// Instance is a managed Postgres server.
+type Instance struct {
+	Name          string            `json:"name"`
+	Role          string            `json:"role"`             // primary | replica
+	Source        string            `json:"source,omitempty"` // replicas only
+	Status        string            `json:"status"`
+	StatusMessage string            `json:"status_message,omitempty"`
+	Region        string            `json:"region,omitempty"`
+	InstanceClass string            `json:"instance_class,omitempty"`
+	EngineVersion string            `json:"engine_version,omitempty"`
+	Database      string            `json:"database,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	// Endpoint is set once the instance is available.
+	Endpoint  *Endpoint `json:"endpoint,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Endpoint is where clients connect to an instance.
+type Endpoint struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+// ErrorResponse is the body of every non-2xx response.
+type ErrorResponse struct {
+	Message string `json:"message"`
+}
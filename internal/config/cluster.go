@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultCluster is the cluster name used when none is configured. Its
+// containers, network and volumes keep the names written in the config.
+const DefaultCluster = "default"
+
+// clusterNamePattern matches names usable as a prefix of container,
+// network and volume names and as a directory name.
+var clusterNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidateClusterName checks a cluster name from the config or a flag.
+func ValidateClusterName(name string) error {
+	if !clusterNamePattern.MatchString(name) {
+		return fmt.Errorf("cluster name %q must start with a lowercase letter or digit and contain only lowercase letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// ClusterName returns cluster.name, or DefaultCluster if unset.
+func (c *Config) ClusterName() string {
+	if c.Cluster.Name == "" {
+		return DefaultCluster
+	}
+	return c.Cluster.Name
+}
+
+// UseCluster selects the cluster this config provisions (name overrides
+// cluster.name unless empty) and namespaces the config for it: outside the
+// default cluster, container, network and named volume names get a
+// "<cluster>-" prefix, host paths move into a "<cluster>" subdirectory of
+// their parent, and every host port is shifted by cluster.port_offset. Two
+// clusters provisioned from the same config therefore only need different
+// names and port offsets. It must be called once, after Load.
+func (c *Config) UseCluster(name string) error {
+	if c.namespaced {
+		return fmt.Errorf("config is already namespaced for cluster %q", c.ClusterName())
+	}
+	if name != "" {
+		if err := ValidateClusterName(name); err != nil {
+			return err
+		}
+		c.Cluster.Name = name
+	}
+	c.namespaced = true
+
+	offset := c.Cluster.PortOffset
+	c.Postgres.Primary.Port += offset
+	if c.Postgres.Replicas.BasePort != 0 {
+		c.Postgres.Replicas.BasePort += offset
+	}
+	for i := range c.Postgres.Replicas.Nodes {
+		c.Postgres.Replicas.Nodes[i].Port += offset
+	}
+
+	cluster := c.ClusterName()
+	if cluster == DefaultCluster {
+		return nil
+	}
+	prefix := func(s string) string {
+		if s == "" {
+			return s
+		}
+		return cluster + "-" + s
+	}
+	// Host paths may be a replica volume prefix such as /srv/pg/replica-,
+	// so the subdirectory goes before the last element rather than after it.
+	volume := func(s string) string {
+		if IsHostPath(s) {
+			i := strings.LastIndexByte(s, '/')
+			return s[:i+1] + cluster + "/" + s[i+1:]
+		}
+		return prefix(s)
+	}
+
+	c.Postgres.Network = prefix(c.Postgres.Network)
+	c.Postgres.Primary.HostName = prefix(c.Postgres.Primary.HostName)
+	c.Postgres.Primary.Volume = volume(c.Postgres.Primary.Volume)
+	c.Postgres.Replicas.NamePrefix = prefix(c.Postgres.Replicas.NamePrefix)
+	c.Postgres.Replicas.VolumePrefix = volume(c.Postgres.Replicas.VolumePrefix)
+	for i := range c.Postgres.Replicas.Nodes {
+		node := &c.Postgres.Replicas.Nodes[i]
+		node.Name = prefix(node.Name)
+		node.Upstream = prefix(node.Upstream)
+		node.Volume = volume(node.Volume)
+	}
+	standbys := c.Postgres.Replication.SynchronousStandbys.Standbys
+	for i := range standbys {
+		standbys[i] = prefix(standbys[i])
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUseClusterDefault(t *testing.T) {
+	c := newTestConfig()
+	c.Cluster.PortOffset = 100
+	c.Postgres.Replicas.Nodes = []ReplicaNode{{Name: "pg-replica-1", Port: 5433, Volume: "pgdata-1"}}
+
+	if err := c.UseCluster(""); err != nil {
+		t.Fatal(err)
+	}
+	if c.ClusterName() != DefaultCluster {
+		t.Errorf("cluster %q, want %q", c.ClusterName(), DefaultCluster)
+	}
+	// The default cluster keeps its names but still honours the offset.
+	if c.Postgres.Network != "pgnet" || c.Postgres.Primary.HostName != "pg-primary" {
+		t.Errorf("names changed: network %q primary %q", c.Postgres.Network, c.Postgres.Primary.HostName)
+	}
+	n := c.Postgres.Replicas.Nodes[0]
+	if n.Name != "pg-replica-1" || n.Volume != "pgdata-1" {
+		t.Errorf("node renamed to %+v", n)
+	}
+	if c.Postgres.Primary.Port != 5532 || n.Port != 5533 {
+		t.Errorf("ports %d and %d, want 5532 and 5533", c.Postgres.Primary.Port, n.Port)
+	}
+}
+
+func TestUseClusterNodes(t *testing.T) {
+	c := newTestConfig()
+	c.Cluster.Name = "ignored"
+	c.Cluster.PortOffset = 10
+	c.Postgres.Primary.Volume = "pgdata-primary"
+	c.Postgres.Replicas.Nodes = []ReplicaNode{
+		{Name: "pg-replica-1", Port: 5433, Volume: "/srv/pg/replica-1"},
+		{Name: "pg-replica-2", Port: 5434, Upstream: "pg-replica-1"},
+	}
+	c.Postgres.Replication.SynchronousStandbys.Standbys = []string{"pg-replica-1"}
+
+	// The name argument wins over cluster.name.
+	if err := c.UseCluster("pg15"); err != nil {
+		t.Fatal(err)
+	}
+	if c.ClusterName() != "pg15" {
+		t.Fatalf("cluster %q, want pg15", c.ClusterName())
+	}
+
+	checks := []struct{ field, got, want string }{
+		{"network", c.Postgres.Network, "pg15-pgnet"},
+		{"primary", c.Postgres.Primary.HostName, "pg15-pg-primary"},
+		{"primary volume", c.Postgres.Primary.Volume, "pg15-pgdata-primary"},
+		{"replica-1", c.Postgres.Replicas.Nodes[0].Name, "pg15-pg-replica-1"},
+		{"host path volume", c.Postgres.Replicas.Nodes[0].Volume, "/srv/pg/pg15/replica-1"},
+		{"anonymous volume", c.Postgres.Replicas.Nodes[1].Volume, ""},
+		{"upstream", c.Postgres.Replicas.Nodes[1].Upstream, "pg15-pg-replica-1"},
+		{"standby", c.Postgres.Replication.SynchronousStandbys.Standbys[0], "pg15-pg-replica-1"},
+	}
+	for _, check := range checks {
+		if check.got != check.want {
+			t.Errorf("%s = %q, want %q", check.field, check.got, check.want)
+		}
+	}
+	if c.Postgres.Primary.Port != 5442 || c.Postgres.Replicas.Nodes[0].Port != 5443 || c.Postgres.Replicas.Nodes[1].Port != 5444 {
+		t.Errorf("ports not shifted by 10: primary %d, replicas %d %d",
+			c.Postgres.Primary.Port, c.Postgres.Replicas.Nodes[0].Port, c.Postgres.Replicas.Nodes[1].Port)
+	}
+
+	// The rewritten topology still resolves, with the default upstream
+	// pointing at the renamed primary.
+	ordered, err := c.OrderedReplicaNodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ordered[0].Upstream != "pg15-pg-primary" {
+		t.Errorf("replica-1 upstream %q, want pg15-pg-primary", ordered[0].Upstream)
+	}
+
+	if err := c.UseCluster("pg15"); err == nil {
+		t.Error("namespacing twice succeeded")
+	}
+}
+
+func TestUseClusterCount(t *testing.T) {
+	c := newTestConfig()
+	c.Cluster.Name = "pg16"
+	c.Cluster.PortOffset = 1000
+	c.Postgres.Replicas.Count = 2
+	c.Postgres.Replicas.BasePort = 5440
+	c.Postgres.Replicas.NamePrefix = "pg-replica-"
+	c.Postgres.Replicas.VolumePrefix = "pgdata-replica-"
+
+	if err := c.UseCluster(""); err != nil {
+		t.Fatal(err)
+	}
+	nodes := c.ReplicaNodes()
+	if got, want := names(nodes), []string{"pg16-pg-replica-1", "pg16-pg-replica-2"}; !slices.Equal(got, want) {
+		t.Errorf("replicas %v, want %v", got, want)
+	}
+	if nodes[1].Port != 6441 || nodes[1].Volume != "pg16-pgdata-replica-2" || nodes[1].Upstream != "pg16-pg-primary" {
+		t.Errorf("replica-2 = %+v", nodes[1])
+	}
+}
+
+func TestUseClusterHostPathPrefix(t *testing.T) {
+	c := newTestConfig()
+	c.Postgres.Primary.Volume = "/srv/pg/primary"
+	c.Postgres.Replicas.Count = 1
+	c.Postgres.Replicas.BasePort = 5433
+	c.Postgres.Replicas.NamePrefix = "pg-replica-"
+	c.Postgres.Replicas.VolumePrefix = "/srv/pg/replica-"
+
+	if err := c.UseCluster("pg16"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Postgres.Primary.Volume; got != "/srv/pg/pg16/primary" {
+		t.Errorf("primary volume %q, want /srv/pg/pg16/primary", got)
+	}
+	if got := c.ReplicaNodes()[0].Volume; got != "/srv/pg/pg16/replica-1" {
+		t.Errorf("replica volume %q, want /srv/pg/pg16/replica-1", got)
+	}
+}
+
+func TestUseClusterRejectsInvalidName(t *testing.T) {
+	c := newTestConfig()
+	if err := c.UseCluster("PG_15"); err == nil {
+		t.Fatal("invalid cluster name accepted")
+	}
+	if c.Postgres.Primary.HostName != "pg-primary" {
+		t.Errorf("config changed by a rejected name: primary %q", c.Postgres.Primary.HostName)
+	}
+}
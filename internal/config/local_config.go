@@ -1,36 +1,205 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+)
+
+// Replication modes supported by postgres.replication.mode.
+const (
+	// ReplicationPhysical runs replicas as streaming physical standbys (default).
+	ReplicationPhysical = "physical"
+	// ReplicationLogical runs replicas as independent servers subscribed to a
+	// publication on the primary.
+	ReplicationLogical = "logical"
+
+	// DefaultPublication is the publication name used in logical mode when
+	// postgres.replication.publication is not set.
+	DefaultPublication = "telemetry_pub"
 
+	// DefaultReadinessTimeout is how long provisioning waits for each node
+	// when postgres.readiness_timeout is not set.
+	DefaultReadinessTimeout = 90 * time.Second
 )
 
+// identifierPattern matches names we are willing to use unquoted as
+// publication, subscription and slot names.
+var identifierPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
 // Config describes the structure of config.example.yaml.
 type Config struct {
 	Version     int    `yaml:"version"`
 	Environment string `yaml:"environment"`
 
+	// Cluster names the cluster so several can run side by side; see
+	// UseCluster.
+	Cluster struct {
+		Name       string `yaml:"name"`
+		PortOffset int    `yaml:"port_offset"` // added to every host port
+	} `yaml:"cluster"`
+
+	// namespaced is set once UseCluster has rewritten the names.
+	namespaced bool
+
 	Postgres struct {
 		Image   string `yaml:"image"`
 		Network string `yaml:"network"`
+
+		// ReadinessTimeout bounds how long provisioning waits for each node
+		// to accept connections (and standbys to stream), as a Go duration.
+		ReadinessTimeout string `yaml:"readiness_timeout"`
+
+		// Parameters are server settings (GUCs) passed to every node as
+		// -c flags; HBA rules are added to every node's pg_hba.conf. The
+		// primary, replicas and individual nodes can override both.
+		Parameters map[string]string `yaml:"parameters"`
+		HBA        []string          `yaml:"hba"`
+
 		Primary struct {
 			HostName     string `yaml:"name"`
 			Port     int    `yaml:"port"`
 			Database string `yaml:"database"`
 			User     string `yaml:"user"`
 			Password string `yaml:"-"` // do not read password from YAML, load from .env or secret management
-	
+
+			// Volume is a named Docker volume or absolute host path for the
+			// data directory. Empty keeps data in anonymous storage.
+			Volume string `yaml:"volume"`
+
+			Parameters map[string]string `yaml:"parameters"`
+			HBA        []string          `yaml:"hba"`
+
+			Resources Resources `yaml:"resources"`
 		} `yaml:"primary"`
 
+		// Replicas are declared either as a count (all streaming from the
+		// primary) or as an explicit list of nodes with upstreams.
 		Replicas struct {
 			Count      int    `yaml:"count"`
 			BasePort   int    `yaml:"base_port"`
 			NamePrefix string `yaml:"name_prefix"`
+			// VolumePrefix gives count-based replica n the volume <prefix><n>.
+			VolumePrefix string `yaml:"volume_prefix"`
+
+			// Parameters, HBA and Resources apply to every replica.
+			Parameters map[string]string `yaml:"parameters"`
+			HBA        []string          `yaml:"hba"`
+			Resources  Resources         `yaml:"resources"`
+
+			Nodes []ReplicaNode `yaml:"nodes"`
 		} `yaml:"replicas"`
+
+		Replication struct {
+			Mode        string `yaml:"mode"`        // physical (default) | logical
+			Publication string `yaml:"publication"` // logical mode only
+
+			// SynchronousStandbys renders to synchronous_standby_names on the
+			// primary. Leaving method empty keeps replication asynchronous.
+			SynchronousStandbys struct {
+				Method   string   `yaml:"method"`   // FIRST | ANY
+				NumSync  int      `yaml:"num_sync"` // standbys that must confirm each commit
+				Standbys []string `yaml:"standbys"` // replica names; empty means any standby (*)
+			} `yaml:"synchronous_standby_names"`
+
+			// SynchronousCommit is the synchronous_commit level set on the
+			// primary (on, off, local, remote_write, remote_apply).
+			SynchronousCommit string `yaml:"synchronous_commit"`
+		} `yaml:"replication"`
 	} `yaml:"postgres"`
+
+	Benchmark struct {
+		// Resources limits the pgbench client container, so the client
+		// does not compete with the servers for the whole machine.
+		Resources Resources `yaml:"resources"`
+	} `yaml:"benchmark"`
+
+	// LocalProc configures the localproc target, which runs the nodes as
+	// host processes instead of containers.
+	LocalProc struct {
+		// DataDir holds one data directory per node (default
+		// .telemetry/localproc/data).
+		DataDir string `yaml:"data_dir"`
+		// BinDir is where initdb, pg_ctl, pg_basebackup, psql and pgbench
+		// live, e.g. /usr/lib/postgresql/16/bin. Empty searches PATH.
+		BinDir string `yaml:"bin_dir"`
+	} `yaml:"localproc"`
+
+	// Kubernetes configures the manifests written by `render k8s`.
+	Kubernetes struct {
+		// Namespace is set on every object; empty leaves it to kubectl.
+		Namespace string `yaml:"namespace"`
+		// SecretName is an existing Secret holding PG_PASSWORD (default
+		// <cluster>-postgres). It is referenced, never rendered.
+		SecretName string `yaml:"secret_name"`
+		// StorageClass and StorageSize shape the PersistentVolumeClaims of
+		// nodes with a volume; nodes without one use an emptyDir.
+		StorageClass string `yaml:"storage_class"`
+		StorageSize  string `yaml:"storage_size"` // default 10Gi
+	} `yaml:"kubernetes"`
+
+	// Cloud configures the cloud target, which creates managed instances
+	// through an HTTP API instead of running servers itself.
+	Cloud struct {
+		// APIURL is the control plane, e.g. http://127.0.0.1:8089 for
+		// `telemetryctl fakecloud serve`. The token is read from
+		// CLOUD_API_TOKEN.
+		APIURL        string `yaml:"api_url"`
+		Region        string `yaml:"region"`
+		InstanceClass string `yaml:"instance_class"`
+		EngineVersion string `yaml:"engine_version"`
+		StorageGB     int    `yaml:"storage_gb"`
+		// PollInterval is how often instance status is polled, as a Go
+		// duration (default 2s). Readiness uses postgres.readiness_timeout.
+		PollInterval string `yaml:"poll_interval"`
+	} `yaml:"cloud"`
+}
+
+// ReplicationMode returns the configured replication mode, defaulting to physical.
+func (c *Config) ReplicationMode() string {
+	if c.Postgres.Replication.Mode == "" {
+		return ReplicationPhysical
+	}
+	return c.Postgres.Replication.Mode
+}
+
+// ReadinessTimeout returns postgres.readiness_timeout, or
+// DefaultReadinessTimeout if unset. Validate rejects unparsable values.
+func (c *Config) ReadinessTimeout() time.Duration {
+	d, err := time.ParseDuration(c.Postgres.ReadinessTimeout)
+	if err != nil || d <= 0 {
+		return DefaultReadinessTimeout
+	}
+	return d
+}
+
+// DefaultCloudPollInterval is how often the cloud target polls instance
+// status when cloud.poll_interval is not set.
+const DefaultCloudPollInterval = 2 * time.Second
+
+// CloudPollInterval returns cloud.poll_interval, or DefaultCloudPollInterval
+// if unset. Validate rejects unparsable values.
+func (c *Config) CloudPollInterval() time.Duration {
+	d, err := time.ParseDuration(c.Cloud.PollInterval)
+	if err != nil || d <= 0 {
+		return DefaultCloudPollInterval
+	}
+	return d
+}
+
+// PublicationName returns the publication used in logical mode.
+func (c *Config) PublicationName() string {
+	if c.Postgres.Replication.Publication == "" {
+		return DefaultPublication
+	}
+	return c.Postgres.Replication.Publication
 }
 
 // Load reads a YAML config file from disk and unmarshals it into Config.
@@ -52,6 +221,36 @@ func Load(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// Hash fingerprints the config, e.g. to label the resources created from it.
+// Secrets are not part of the YAML and do not affect it.
+func (c *Config) Hash() string {
+	data, _ := yaml.Marshal(c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// synchronousCommitLevels are the accepted values of synchronous_commit.
+var synchronousCommitLevels = []string{"on", "off", "local", "remote_write", "remote_apply"}
+
+// SynchronousStandbyNames renders postgres.replication.synchronous_standby_names
+// in Postgres syntax, e.g. `ANY 1 ("pg-replica-1", "pg-replica-2")`. It
+// returns "" when synchronous replication is not configured.
+func (c *Config) SynchronousStandbyNames() string {
+	sync := c.Postgres.Replication.SynchronousStandbys
+	if sync.Method == "" {
+		return ""
+	}
+	names := "*"
+	if len(sync.Standbys) > 0 {
+		quoted := make([]string, len(sync.Standbys))
+		for i, name := range sync.Standbys {
+			quoted[i] = `"` + name + `"`
+		}
+		names = strings.Join(quoted, ", ")
+	}
+	return fmt.Sprintf("%s %d (%s)", strings.ToUpper(sync.Method), sync.NumSync, names)
+}
+
 // Validate performs basic sanity checks on the configuration.
 func (c *Config) Validate() error {
 	if c.Version == 0 {
@@ -69,10 +268,42 @@ func (c *Config) Validate() error {
 	if c.Postgres.Primary.Port == 0 {
 		return fmt.Errorf("postgres.primary.port must be > 0")
 	}
+	if c.Cluster.Name != "" {
+		if err := ValidateClusterName(c.Cluster.Name); err != nil {
+			return fmt.Errorf("cluster.name: %w", err)
+		}
+	}
+	if c.Cluster.PortOffset < 0 {
+		return fmt.Errorf("cluster.port_offset cannot be negative")
+	}
+	if c.Postgres.ReadinessTimeout != "" {
+		d, err := time.ParseDuration(c.Postgres.ReadinessTimeout)
+		if err != nil {
+			return fmt.Errorf("postgres.readiness_timeout: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("postgres.readiness_timeout must be > 0")
+		}
+	}
+	if c.Cloud.PollInterval != "" {
+		d, err := time.ParseDuration(c.Cloud.PollInterval)
+		if err != nil {
+			return fmt.Errorf("cloud.poll_interval: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("cloud.poll_interval must be > 0")
+		}
+	}
+	if c.Cloud.StorageGB < 0 {
+		return fmt.Errorf("cloud.storage_gb cannot be negative")
+	}
 	if c.Postgres.Replicas.Count < 0 {
 		return fmt.Errorf("postgres.replicas.count cannot be negative")
 	}
 	if c.Postgres.Replicas.Count > 0 {
+		if len(c.Postgres.Replicas.Nodes) > 0 {
+			return fmt.Errorf("postgres.replicas.count and postgres.replicas.nodes are mutually exclusive")
+		}
 		if c.Postgres.Replicas.BasePort == 0 {
 			return fmt.Errorf("postgres.replicas.base_port must be > 0 when replicas.count > 0")
 		}
@@ -80,5 +311,83 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("postgres.replicas.name_prefix must be set when replicas.count > 0")
 		}
 	}
+	if c.Postgres.Replicas.VolumePrefix != "" && len(c.Postgres.Replicas.Nodes) > 0 {
+		return fmt.Errorf("postgres.replicas.volume_prefix only applies to count-based replicas; set volume per node instead")
+	}
+	if err := c.validateTopology(); err != nil {
+		return err
+	}
+	if err := c.validateVolumes(); err != nil {
+		return err
+	}
+	if err := c.validateParameters(); err != nil {
+		return err
+	}
+	if err := c.validateResources(); err != nil {
+		return err
+	}
+	switch c.ReplicationMode() {
+	case ReplicationPhysical:
+		if c.Postgres.Replication.Publication != "" {
+			return fmt.Errorf("postgres.replication.publication is only valid with mode %q", ReplicationLogical)
+		}
+	case ReplicationLogical:
+		if !identifierPattern.MatchString(c.PublicationName()) {
+			return fmt.Errorf("postgres.replication.publication %q must contain only lowercase letters, digits and underscores", c.PublicationName())
+		}
+	default:
+		return fmt.Errorf("postgres.replication.mode must be %q or %q, got %q", ReplicationPhysical, ReplicationLogical, c.Postgres.Replication.Mode)
+	}
+	if err := c.validateSynchronous(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateSynchronous checks the synchronous replication settings.
+func (c *Config) validateSynchronous() error {
+	repl := c.Postgres.Replication
+	if repl.SynchronousCommit != "" && !slices.Contains(synchronousCommitLevels, repl.SynchronousCommit) {
+		return fmt.Errorf("postgres.replication.synchronous_commit must be one of %s, got %q",
+			strings.Join(synchronousCommitLevels, ", "), repl.SynchronousCommit)
+	}
+
+	sync := repl.SynchronousStandbys
+	if sync.Method == "" {
+		if sync.NumSync != 0 || len(sync.Standbys) > 0 {
+			return fmt.Errorf("postgres.replication.synchronous_standby_names.method must be set (FIRST or ANY)")
+		}
+		return nil
+	}
+	if c.ReplicationMode() != ReplicationPhysical {
+		return fmt.Errorf("postgres.replication.synchronous_standby_names is only valid with mode %q", ReplicationPhysical)
+	}
+	if m := strings.ToUpper(sync.Method); m != "FIRST" && m != "ANY" {
+		return fmt.Errorf("postgres.replication.synchronous_standby_names.method must be FIRST or ANY, got %q", sync.Method)
+	}
+	if sync.NumSync < 1 {
+		return fmt.Errorf("postgres.replication.synchronous_standby_names.num_sync must be >= 1")
+	}
+
+	// Only standbys streaming directly from the primary can confirm commits.
+	var direct []string
+	for _, node := range c.ReplicaNodes() {
+		if node.Upstream == c.Postgres.Primary.HostName {
+			direct = append(direct, node.Name)
+		}
+	}
+	candidates := len(direct)
+	if len(sync.Standbys) > 0 {
+		candidates = len(sync.Standbys)
+		for _, name := range sync.Standbys {
+			if !slices.Contains(direct, name) {
+				return fmt.Errorf("postgres.replication.synchronous_standby_names.standbys: %q is not a replica streaming from the primary", name)
+			}
+		}
+	}
+	if sync.NumSync > candidates {
+		return fmt.Errorf("postgres.replication.synchronous_standby_names.num_sync (%d) exceeds the number of candidate standbys (%d)",
+			sync.NumSync, candidates)
+	}
 	return nil
 }
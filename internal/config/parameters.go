@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parameterNamePattern matches GUC names, including custom ones of
+// extensions (e.g. pg_stat_statements.max).
+var parameterNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\.[a-z_][a-z0-9_]*)?$`)
+
+// reservedParameters are set by telemetryctl itself from the replication
+// settings; overriding them would break replication, failover or pg_rewind.
+var reservedParameters = map[string]string{
+	"wal_level":                 "postgres.replication.mode",
+	"hot_standby":               "postgres.replication.mode",
+	"wal_log_hints":             "switchover (pg_rewind needs it)",
+	"primary_conninfo":          "postgres.replicas",
+	"primary_slot_name":         "postgres.replicas",
+	"recovery_min_apply_delay":  "postgres.replicas.nodes[].apply_delay",
+	"synchronous_standby_names": "postgres.replication.synchronous_standby_names",
+	"synchronous_commit":        "postgres.replication.synchronous_commit",
+	"port":                      "postgres.primary.port and the replica ports",
+	"listen_addresses":          "the container network",
+	"data_directory":            "postgres.primary.volume and the replica volumes",
+	"config_file":               "postgres.parameters",
+	"hba_file":                  "postgres.hba",
+}
+
+// standbyMinimumParameters must be at least as high on a hot standby as on
+// its primary, or the standby refuses to start.
+var standbyMinimumParameters = []string{
+	"max_connections",
+	"max_worker_processes",
+	"max_wal_senders",
+	"max_prepared_transactions",
+	"max_locks_per_transaction",
+}
+
+// hbaConnectionTypes are the first field of a pg_hba.conf record.
+var hbaConnectionTypes = []string{"local", "host", "hostssl", "hostnossl", "hostgssenc", "hostnogssenc"}
+
+// PrimaryParameters returns the server parameters of the primary:
+// postgres.parameters overridden by postgres.primary.parameters.
+func (c *Config) PrimaryParameters() map[string]string {
+	return mergeParameters(c.Postgres.Parameters, c.Postgres.Primary.Parameters)
+}
+
+// ReplicaParameters returns the server parameters of a replica:
+// postgres.parameters, then postgres.replicas.parameters, then the node's own.
+func (c *Config) ReplicaParameters(node ReplicaNode) map[string]string {
+	return mergeParameters(c.Postgres.Parameters, c.Postgres.Replicas.Parameters, node.Parameters)
+}
+
+// PrimaryHBA returns the pg_hba.conf rules added on the primary.
+func (c *Config) PrimaryHBA() []string {
+	return slices.Concat(c.Postgres.HBA, c.Postgres.Primary.HBA)
+}
+
+// ReplicaHBA returns the pg_hba.conf rules added on a replica.
+func (c *Config) ReplicaHBA(node ReplicaNode) []string {
+	return slices.Concat(c.Postgres.HBA, c.Postgres.Replicas.HBA, node.HBA)
+}
+
+// mergeParameters layers parameter maps, later ones winning.
+func mergeParameters(layers ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, layer := range layers {
+		maps.Copy(merged, layer)
+	}
+	return merged
+}
+
+// validateParameters checks parameter names and pg_hba.conf rules at every
+// level, and that physical standbys will be able to start.
+func (c *Config) validateParameters() error {
+	type level struct {
+		field  string
+		params map[string]string
+		hba    []string
+	}
+	levels := []level{
+		{"postgres", c.Postgres.Parameters, c.Postgres.HBA},
+		{"postgres.primary", c.Postgres.Primary.Parameters, c.Postgres.Primary.HBA},
+		{"postgres.replicas", c.Postgres.Replicas.Parameters, c.Postgres.Replicas.HBA},
+	}
+	for i, node := range c.Postgres.Replicas.Nodes {
+		levels = append(levels, level{fmt.Sprintf("postgres.replicas.nodes[%d]", i), node.Parameters, node.HBA})
+	}
+
+	for _, l := range levels {
+		for name := range l.params {
+			if !parameterNamePattern.MatchString(name) {
+				return fmt.Errorf("%s.parameters: %q is not a valid parameter name", l.field, name)
+			}
+			if managedBy, ok := reservedParameters[name]; ok {
+				return fmt.Errorf("%s.parameters: %q is managed by telemetryctl, use %s instead", l.field, name, managedBy)
+			}
+		}
+		for j, rule := range l.hba {
+			if err := validateHBARule(rule); err != nil {
+				return fmt.Errorf("%s.hba[%d]: %w", l.field, j, err)
+			}
+		}
+	}
+
+	if c.ReplicationMode() != ReplicationPhysical {
+		return nil
+	}
+	primary := c.PrimaryParameters()
+	for _, node := range c.ReplicaNodes() {
+		replica := c.ReplicaParameters(node)
+		for _, name := range standbyMinimumParameters {
+			want, ok := primary[name]
+			if !ok {
+				continue
+			}
+			got, ok := replica[name]
+			if !ok {
+				return fmt.Errorf("replica %q: %s is set to %s on the primary only; standbys need at least the same value, "+
+					"set it in postgres.parameters instead", node.Name, name, want)
+			}
+			w, errW := strconv.Atoi(want)
+			g, errG := strconv.Atoi(got)
+			if errW == nil && errG == nil && g < w {
+				return fmt.Errorf("replica %q: %s (%d) must be at least the primary's (%d)", node.Name, name, g, w)
+			}
+		}
+	}
+	return nil
+}
+
+// validateHBARule performs a light syntax check of a pg_hba.conf record.
+func validateHBARule(rule string) error {
+	if strings.ContainsAny(rule, "\n\r") {
+		return fmt.Errorf("rule %q must be a single line", rule)
+	}
+	fields := strings.Fields(rule)
+	if len(fields) == 0 {
+		return fmt.Errorf("rule must not be empty")
+	}
+	if !slices.Contains(hbaConnectionTypes, fields[0]) {
+		return fmt.Errorf("rule %q must start with one of %s", rule, strings.Join(hbaConnectionTypes, ", "))
+	}
+	// local: type, database, user, method; host*: plus an address.
+	minFields := 5
+	if fields[0] == "local" {
+		minFields = 4
+	}
+	if len(fields) < minFields {
+		return fmt.Errorf("rule %q has too few fields", rule)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestReplicaParametersLayering(t *testing.T) {
+	c := newTestConfig()
+	c.Postgres.Parameters = map[string]string{"shared_buffers": "128MB", "work_mem": "4MB"}
+	c.Postgres.Replicas.Parameters = map[string]string{"work_mem": "8MB"}
+	n := ReplicaNode{Name: "a", Port: 5433, Parameters: map[string]string{"shared_buffers": "256MB"}}
+
+	want := map[string]string{"shared_buffers": "256MB", "work_mem": "8MB"}
+	if got := c.ReplicaParameters(n); !maps.Equal(got, want) {
+		t.Errorf("ReplicaParameters = %v, want %v", got, want)
+	}
+	if got := c.PrimaryParameters(); got["work_mem"] != "4MB" {
+		t.Errorf("primary work_mem = %q, want 4MB", got["work_mem"])
+	}
+}
+
+func TestValidateParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(c *Config)
+		wantErr string // empty means valid
+	}{
+		{
+			name: "custom and extension parameters",
+			setup: func(c *Config) {
+				c.Postgres.Parameters = map[string]string{"shared_buffers": "256MB", "pg_stat_statements.max": "5000"}
+			},
+		},
+		{
+			name: "invalid name",
+			setup: func(c *Config) {
+				c.Postgres.Parameters = map[string]string{"Shared-Buffers": "256MB"}
+			},
+			wantErr: `postgres.parameters: "Shared-Buffers" is not a valid parameter name`,
+		},
+		{
+			name: "reserved on the primary",
+			setup: func(c *Config) {
+				c.Postgres.Primary.Parameters = map[string]string{"wal_level": "minimal"}
+			},
+			wantErr: `postgres.primary.parameters: "wal_level" is managed by telemetryctl, use postgres.replication.mode instead`,
+		},
+		{
+			name: "reserved on a node",
+			setup: func(c *Config) {
+				c.Postgres.Replicas.Nodes = []ReplicaNode{
+					{Name: "a", Port: 5433, Parameters: map[string]string{"primary_conninfo": "host=x"}},
+				}
+			},
+			wantErr: `postgres.replicas.nodes[0].parameters: "primary_conninfo" is managed by telemetryctl`,
+		},
+		{
+			name: "standby minimum set on the primary only",
+			setup: func(c *Config) {
+				c.Postgres.Primary.Parameters = map[string]string{"max_connections": "200"}
+				c.Postgres.Replicas.Nodes = []ReplicaNode{{Name: "a", Port: 5433}}
+			},
+			wantErr: `replica "a": max_connections is set to 200 on the primary only`,
+		},
+		{
+			name: "standby minimum lowered on a replica",
+			setup: func(c *Config) {
+				c.Postgres.Parameters = map[string]string{"max_wal_senders": "10"}
+				c.Postgres.Replicas.Nodes = []ReplicaNode{
+					{Name: "a", Port: 5433, Parameters: map[string]string{"max_wal_senders": "5"}},
+				}
+			},
+			wantErr: `replica "a": max_wal_senders (5) must be at least the primary's (10)`,
+		},
+		{
+			name: "standby minimum raised on a replica",
+			setup: func(c *Config) {
+				c.Postgres.Parameters = map[string]string{"max_connections": "100"}
+				c.Postgres.Replicas.Parameters = map[string]string{"max_connections": "300"}
+				c.Postgres.Replicas.Nodes = []ReplicaNode{{Name: "a", Port: 5433}}
+			},
+		},
+		{
+			name: "standby minimums do not apply in logical mode",
+			setup: func(c *Config) {
+				c.Postgres.Replication.Mode = ReplicationLogical
+				c.Postgres.Primary.Parameters = map[string]string{"max_connections": "200"}
+				c.Postgres.Replicas.Nodes = []ReplicaNode{{Name: "a", Port: 5433}}
+			},
+		},
+		{
+			name: "valid hba rules",
+			setup: func(c *Config) {
+				c.Postgres.HBA = []string{
+					"host all all 10.0.0.0/8 scram-sha-256",
+					"local all postgres peer",
+				}
+			},
+		},
+		{
+			name: "hba with unknown type",
+			setup: func(c *Config) {
+				c.Postgres.Replicas.HBA = []string{"hots all all 0.0.0.0/0 trust"}
+			},
+			wantErr: "postgres.replicas.hba[0]: rule \"hots all all 0.0.0.0/0 trust\" must start with one of",
+		},
+		{
+			name: "hba host rule without address",
+			setup: func(c *Config) {
+				c.Postgres.Primary.HBA = []string{"host all all trust"}
+			},
+			wantErr: "postgres.primary.hba[0]: rule \"host all all trust\" has too few fields",
+		},
+		{
+			name: "hba rule spanning lines",
+			setup: func(c *Config) {
+				c.Postgres.HBA = []string{"local all all trust\nhost all all 0.0.0.0/0 trust"}
+			},
+			wantErr: "must be a single line",
+		},
+		{
+			name: "empty hba rule",
+			setup: func(c *Config) {
+				c.Postgres.HBA = []string{"  "}
+			},
+			wantErr: "postgres.hba[0]: rule must not be empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConfig()
+			tt.setup(c)
+			err := c.validateParameters()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Resources limits the CPU and memory of a container. Empty fields mean no
+// limit (shm_size falls back to Docker's 64 MB default).
+type Resources struct {
+	CPUs    string `yaml:"cpus"`     // e.g. "1.5"
+	Memory  string `yaml:"memory"`   // e.g. "2g", "512m"
+	ShmSize string `yaml:"shm_size"` // e.g. "256m"
+}
+
+// ResourceLimits are Resources in the units the container runtime expects.
+// Zero means unlimited.
+type ResourceLimits struct {
+	NanoCPUs     int64
+	MemoryBytes  int64
+	ShmSizeBytes int64
+}
+
+// Limits parses the resource settings.
+func (r Resources) Limits() (ResourceLimits, error) {
+	var l ResourceLimits
+	if r.CPUs != "" {
+		cpus, err := strconv.ParseFloat(r.CPUs, 64)
+		if err != nil || cpus <= 0 {
+			return l, fmt.Errorf("cpus %q must be a positive number", r.CPUs)
+		}
+		l.NanoCPUs = int64(cpus * 1e9)
+	}
+	var err error
+	if l.MemoryBytes, err = parseSize(r.Memory); err != nil {
+		return l, fmt.Errorf("memory: %w", err)
+	}
+	if l.ShmSizeBytes, err = parseSize(r.ShmSize); err != nil {
+		return l, fmt.Errorf("shm_size: %w", err)
+	}
+	return l, nil
+}
+
+// Merge returns r with the fields set in override replacing its own.
+func (r Resources) Merge(override Resources) Resources {
+	if override.CPUs != "" {
+		r.CPUs = override.CPUs
+	}
+	if override.Memory != "" {
+		r.Memory = override.Memory
+	}
+	if override.ShmSize != "" {
+		r.ShmSize = override.ShmSize
+	}
+	return r
+}
+
+// ReplicaResources returns the limits of a replica: postgres.replicas.resources
+// overridden field by field by the node's own.
+func (c *Config) ReplicaResources(node ReplicaNode) Resources {
+	return c.Postgres.Replicas.Resources.Merge(node.Resources)
+}
+
+// sizeUnits are the suffixes accepted by parseSize, as in `docker run`.
+var sizeUnits = map[string]int64{
+	"":   1,
+	"b":  1,
+	"k":  1 << 10,
+	"kb": 1 << 10,
+	"m":  1 << 20,
+	"mb": 1 << 20,
+	"g":  1 << 30,
+	"gb": 1 << 30,
+}
+
+// parseSize parses a byte size such as "512m" or "2g" (binary units).
+// An empty string is zero.
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	lower := strings.ToLower(strings.TrimSpace(s))
+	num := strings.TrimRight(lower, "bkmg")
+	unit, ok := sizeUnits[lower[len(num):]]
+	if !ok {
+		return 0, fmt.Errorf("%q has an unknown unit (use b, k, m or g)", s)
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q must be a positive size such as 512m or 2g", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// validateResources checks every resources block.
+func (c *Config) validateResources() error {
+	check := func(field string, r Resources) error {
+		if _, err := r.Limits(); err != nil {
+			return fmt.Errorf("%s.resources: %w", field, err)
+		}
+		return nil
+	}
+	if err := check("postgres.primary", c.Postgres.Primary.Resources); err != nil {
+		return err
+	}
+	if err := check("postgres.replicas", c.Postgres.Replicas.Resources); err != nil {
+		return err
+	}
+	for i, node := range c.Postgres.Replicas.Nodes {
+		if err := check(fmt.Sprintf("postgres.replicas.nodes[%d]", i), node.Resources); err != nil {
+			return err
+		}
+	}
+	return check("benchmark", c.Benchmark.Resources)
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReplicaNode declares a single replica in postgres.replicas.nodes.
+type ReplicaNode struct {
+	Name string `yaml:"name"`
+	Port int    `yaml:"port"`
+	// Upstream is the node this replica streams from: the primary (default)
+	// or another replica, which makes this a cascading standby.
+	Upstream string `yaml:"upstream"`
+	// ApplyDelay sets recovery_min_apply_delay on this standby, as a Go
+	// duration (e.g. "5m", "1h30m"). Empty means no delay.
+	ApplyDelay string `yaml:"apply_delay"`
+	// Volume keeps the data directory in a named Docker volume or, if it is
+	// an absolute path, a host directory. Empty means anonymous storage.
+	Volume string `yaml:"volume"`
+
+	// Parameters and HBA override those of postgres and postgres.replicas
+	// for this node.
+	Parameters map[string]string `yaml:"parameters"`
+	HBA        []string          `yaml:"hba"`
+
+	// Resources overrides postgres.replicas.resources field by field.
+	Resources Resources `yaml:"resources"`
+}
+
+// ApplyDelayDuration parses ApplyDelay. An empty value is a zero delay.
+func (n ReplicaNode) ApplyDelayDuration() (time.Duration, error) {
+	if n.ApplyDelay == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(n.ApplyDelay)
+	if err != nil {
+		return 0, fmt.Errorf("parsing apply_delay %q: %w", n.ApplyDelay, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("apply_delay %q cannot be negative", n.ApplyDelay)
+	}
+	return d, nil
+}
+
+// ReplicaNodes returns every configured replica with its upstream resolved,
+// in declaration order. Count-based replicas are expanded to
+// <name_prefix><n> on base_port+n-1, all streaming from the primary.
+func (c *Config) ReplicaNodes() []ReplicaNode {
+	primary := c.Postgres.Primary.HostName
+
+	if len(c.Postgres.Replicas.Nodes) > 0 {
+		nodes := make([]ReplicaNode, len(c.Postgres.Replicas.Nodes))
+		for i, node := range c.Postgres.Replicas.Nodes {
+			if node.Upstream == "" {
+				node.Upstream = primary
+			}
+			nodes[i] = node
+		}
+		return nodes
+	}
+
+	nodes := make([]ReplicaNode, c.Postgres.Replicas.Count)
+	for i := range nodes {
+		nodes[i] = c.CountReplicaNode(i + 1)
+	}
+	return nodes
+}
+
+// CountReplicaNode returns the n-th (1-based) count-based replica:
+// <name_prefix><n> on base_port+n-1 streaming from the primary, with data in
+// <volume_prefix><n> if a volume prefix is set.
+func (c *Config) CountReplicaNode(n int) ReplicaNode {
+	node := ReplicaNode{
+		Name:     fmt.Sprintf("%s%d", c.Postgres.Replicas.NamePrefix, n),
+		Port:     c.Postgres.Replicas.BasePort + n - 1,
+		Upstream: c.Postgres.Primary.HostName,
+	}
+	if c.Postgres.Replicas.VolumePrefix != "" {
+		node.Volume = fmt.Sprintf("%s%d", c.Postgres.Replicas.VolumePrefix, n)
+	}
+	return node
+}
+
+// ReplicaNames returns the container names of all configured replicas.
+func (c *Config) ReplicaNames() []string {
+	nodes := c.ReplicaNodes()
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		names[i] = node.Name
+	}
+	return names
+}
+
+// OrderedReplicaNodes returns the replicas ordered so that every node comes
+// after its upstream, which is the order they must be created in.
+// Siblings keep their declaration order.
+func (c *Config) OrderedReplicaNodes() ([]ReplicaNode, error) {
+	nodes := c.ReplicaNodes()
+	primary := c.Postgres.Primary.HostName
+
+	children := make(map[string][]ReplicaNode, len(nodes))
+	for _, node := range nodes {
+		children[node.Upstream] = append(children[node.Upstream], node)
+	}
+
+	// Breadth-first from the primary: anything unreachable is part of a
+	// cycle or hangs off an unknown upstream.
+	ordered := make([]ReplicaNode, 0, len(nodes))
+	queue := []string{primary}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, child := range children[parent] {
+			ordered = append(ordered, child)
+			queue = append(queue, child.Name)
+		}
+	}
+	if len(ordered) != len(nodes) {
+		placed := make(map[string]bool, len(ordered))
+		for _, node := range ordered {
+			placed[node.Name] = true
+		}
+		var stuck []string
+		for _, node := range nodes {
+			if !placed[node.Name] {
+				stuck = append(stuck, node.Name)
+			}
+		}
+		return nil, fmt.Errorf("replicas %s are not connected to the primary (upstream cycle)", strings.Join(stuck, ", "))
+	}
+	return ordered, nil
+}
+
+// validateTopology checks explicitly declared replica nodes: unique names and
+// ports, known upstreams and no cycles.
+func (c *Config) validateTopology() error {
+	primary := c.Postgres.Primary.HostName
+
+	names := map[string]bool{primary: true}
+	ports := map[int]string{c.Postgres.Primary.Port: primary}
+	for i, node := range c.Postgres.Replicas.Nodes {
+		if node.Name == "" {
+			return fmt.Errorf("postgres.replicas.nodes[%d].name must be set", i)
+		}
+		if names[node.Name] {
+			return fmt.Errorf("postgres.replicas.nodes[%d].name %q is used more than once", i, node.Name)
+		}
+		names[node.Name] = true
+
+		if node.Port <= 0 {
+			return fmt.Errorf("postgres.replicas.nodes[%d].port must be > 0", i)
+		}
+		if other, ok := ports[node.Port]; ok {
+			return fmt.Errorf("postgres.replicas.nodes[%d].port %d is already used by %q", i, node.Port, other)
+		}
+		ports[node.Port] = node.Name
+
+		if _, err := node.ApplyDelayDuration(); err != nil {
+			return fmt.Errorf("postgres.replicas.nodes[%d] (%q): %w", i, node.Name, err)
+		}
+		if node.ApplyDelay != "" && c.ReplicationMode() == ReplicationLogical {
+			return fmt.Errorf("postgres.replicas.nodes[%d] (%q): apply_delay is only supported with mode %q", i, node.Name, ReplicationPhysical)
+		}
+	}
+
+	for i, node := range c.Postgres.Replicas.Nodes {
+		if node.Upstream == "" || node.Upstream == primary {
+			continue
+		}
+		if node.Upstream == node.Name {
+			return fmt.Errorf("postgres.replicas.nodes[%d] (%q) cannot be its own upstream", i, node.Name)
+		}
+		if !names[node.Upstream] {
+			return fmt.Errorf("postgres.replicas.nodes[%d] (%q): upstream %q is not the primary or a declared replica", i, node.Name, node.Upstream)
+		}
+		if c.ReplicationMode() == ReplicationLogical {
+			return fmt.Errorf("postgres.replicas.nodes[%d] (%q): cascading upstreams are only supported with mode %q", i, node.Name, ReplicationPhysical)
+		}
+	}
+
+	if _, err := c.OrderedReplicaNodes(); err != nil {
+		return fmt.Errorf("postgres.replicas.nodes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestConfig returns a minimal valid config: a primary pg-primary on
+// 5432 and no replicas.
+func newTestConfig() *Config {
+	var c Config
+	c.Version = 1
+	c.Environment = "test"
+	c.Postgres.Image = "postgres:16"
+	c.Postgres.Network = "pgnet"
+	c.Postgres.Primary.HostName = "pg-primary"
+	c.Postgres.Primary.Port = 5432
+	return &c
+}
+
+// node declares a replica; an empty upstream means the primary.
+func node(name string, port int, upstream string) ReplicaNode {
+	return ReplicaNode{Name: name, Port: port, Upstream: upstream}
+}
+
+func names(nodes []ReplicaNode) []string {
+	out := make([]string, len(nodes))
+	for i, n := range nodes {
+		out[i] = n.Name
+	}
+	return out
+}
+
+func TestOrderedReplicaNodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []ReplicaNode
+		want  []string
+	}{
+		{
+			name: "all from the primary keep declaration order",
+			nodes: []ReplicaNode{
+				node("b", 5434, ""),
+				node("a", 5433, ""),
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			name: "cascade declared before its upstream",
+			nodes: []ReplicaNode{
+				node("c", 5435, "b"),
+				node("b", 5434, "a"),
+				node("a", 5433, ""),
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "breadth first",
+			nodes: []ReplicaNode{
+				node("a1", 5435, "a"),
+				node("a", 5433, "pg-primary"),
+				node("b1", 5436, "b"),
+				node("b", 5434, ""),
+				node("a2", 5437, "a"),
+			},
+			want: []string{"a", "b", "a1", "a2", "b1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConfig()
+			c.Postgres.Replicas.Nodes = tt.nodes
+			got, err := c.OrderedReplicaNodes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(names(got), tt.want) {
+				t.Errorf("order %v, want %v", names(got), tt.want)
+			}
+			for _, n := range got {
+				if n.Upstream == "" {
+					t.Errorf("%s has no resolved upstream", n.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestOrderedReplicaNodesCount(t *testing.T) {
+	c := newTestConfig()
+	c.Postgres.Replicas.Count = 2
+	c.Postgres.Replicas.BasePort = 5440
+	c.Postgres.Replicas.NamePrefix = "pg-replica-"
+	c.Postgres.Replicas.VolumePrefix = "pgdata-replica-"
+
+	got, err := c.OrderedReplicaNodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ReplicaNode{
+		{Name: "pg-replica-1", Port: 5440, Upstream: "pg-primary", Volume: "pgdata-replica-1"},
+		{Name: "pg-replica-2", Port: 5441, Upstream: "pg-primary", Volume: "pgdata-replica-2"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d nodes, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || got[i].Port != want[i].Port ||
+			got[i].Upstream != want[i].Upstream || got[i].Volume != want[i].Volume {
+			t.Errorf("node %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestValidateTopology(t *testing.T) {
+	tests := []struct {
+		name    string
+		nodes   []ReplicaNode
+		logical bool
+		wantErr string // empty means valid
+	}{
+		{
+			name:  "cascade",
+			nodes: []ReplicaNode{node("a", 5433, ""), node("b", 5434, "a")},
+		},
+		{
+			name:    "missing name",
+			nodes:   []ReplicaNode{node("", 5433, "")},
+			wantErr: "nodes[0].name must be set",
+		},
+		{
+			name:    "duplicate name",
+			nodes:   []ReplicaNode{node("a", 5433, ""), node("a", 5434, "")},
+			wantErr: `nodes[1].name "a" is used more than once`,
+		},
+		{
+			name:    "name of the primary",
+			nodes:   []ReplicaNode{node("pg-primary", 5433, "")},
+			wantErr: "used more than once",
+		},
+		{
+			name:    "port of the primary",
+			nodes:   []ReplicaNode{node("a", 5432, "")},
+			wantErr: `port 5432 is already used by "pg-primary"`,
+		},
+		{
+			name:    "missing port",
+			nodes:   []ReplicaNode{node("a", 0, "")},
+			wantErr: "nodes[0].port must be > 0",
+		},
+		{
+			name:    "self upstream",
+			nodes:   []ReplicaNode{node("a", 5433, "a")},
+			wantErr: "cannot be its own upstream",
+		},
+		{
+			name:    "unknown upstream",
+			nodes:   []ReplicaNode{node("a", 5433, "ghost")},
+			wantErr: `upstream "ghost" is not the primary or a declared replica`,
+		},
+		{
+			name: "cycle",
+			nodes: []ReplicaNode{
+				node("a", 5433, ""),
+				node("b", 5434, "c"),
+				node("c", 5435, "b"),
+			},
+			wantErr: "replicas b, c are not connected to the primary (upstream cycle)",
+		},
+		{
+			name:    "cascade in logical mode",
+			nodes:   []ReplicaNode{node("a", 5433, ""), node("b", 5434, "a")},
+			logical: true,
+			wantErr: "cascading upstreams are only supported",
+		},
+		{
+			name:    "invalid apply delay",
+			nodes:   []ReplicaNode{{Name: "a", Port: 5433, ApplyDelay: "soon"}},
+			wantErr: `parsing apply_delay "soon"`,
+		},
+		{
+			name:    "negative apply delay",
+			nodes:   []ReplicaNode{{Name: "a", Port: 5433, ApplyDelay: "-1m"}},
+			wantErr: "cannot be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConfig()
+			c.Postgres.Replicas.Nodes = tt.nodes
+			if tt.logical {
+				c.Postgres.Replication.Mode = ReplicationLogical
+			}
+			err := c.validateTopology()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
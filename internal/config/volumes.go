@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// volumeNamePattern matches the names Docker accepts for named volumes.
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// IsHostPath reports whether a node volume refers to a host directory
+// (bind mount) rather than a named Docker volume.
+func IsHostPath(volume string) bool {
+	return strings.ContainsRune(volume, '/')
+}
+
+// validateVolumes checks that every node volume is a valid volume name or an
+// absolute host path and that no two nodes share one.
+func (c *Config) validateVolumes() error {
+	used := make(map[string]string)
+	check := func(field, node, volume string) error {
+		if volume == "" {
+			return nil
+		}
+		if IsHostPath(volume) {
+			if !filepath.IsAbs(volume) {
+				return fmt.Errorf("%s %q must be an absolute host path or a volume name", field, volume)
+			}
+		} else if !volumeNamePattern.MatchString(volume) {
+			return fmt.Errorf("%s %q is not a valid volume name", field, volume)
+		}
+		if other, ok := used[volume]; ok {
+			return fmt.Errorf("%s %q is already used by %q", field, volume, other)
+		}
+		used[volume] = node
+		return nil
+	}
+
+	if err := check("postgres.primary.volume", c.Postgres.Primary.HostName, c.Postgres.Primary.Volume); err != nil {
+		return err
+	}
+	if prefix := c.Postgres.Replicas.VolumePrefix; prefix != "" && IsHostPath(prefix) && !filepath.IsAbs(prefix) {
+		return fmt.Errorf("postgres.replicas.volume_prefix %q must be an absolute host path or a volume name", prefix)
+	}
+	for i, node := range c.ReplicaNodes() {
+		field := fmt.Sprintf("postgres.replicas.nodes[%d].volume", i)
+		if len(c.Postgres.Replicas.Nodes) == 0 {
+			field = "postgres.replicas.volume_prefix of " + node.Name
+		}
+		if err := check(field, node.Name, node.Volume); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+// Package docker is a small Docker Engine API client covering what the
+// provisioning and benchmark code needs: containers, networks, images, exec
+// and logs. It talks HTTP over the daemon's unix socket (or TCP when
+// DOCKER_HOST says so) and can be pointed at any HTTP server, e.g. a fake
+// daemon in tests.
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	// apiVersion is the Engine API version requests are pinned to
+	// (Docker 20.10 and later).
+	apiVersion = "v1.41"
+
+	defaultSocket = "/var/run/docker.sock"
+)
+
+// Client is a Docker Engine API client.
+type Client struct {
+	http    *http.Client
+	baseURL string
+	// publishHost is where ports published by the daemon are reachable;
+	// empty means the local machine.
+	publishHost string
+}
+
+// NewClient returns a client for the daemon named by DOCKER_HOST, or the
+// default unix socket if it is unset. unix:// and tcp:// hosts are supported.
+func NewClient() (*Client, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = "unix://" + defaultSocket
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("parsing DOCKER_HOST %q: %w", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		// The host part is ignored by the dialer but must be a valid name.
+		return NewClientWithHTTP("http://docker", &http.Client{Transport: transport}), nil
+	case "tcp", "http":
+		c := NewClientWithHTTP("http://"+u.Host, &http.Client{})
+		c.publishHost = u.Hostname()
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unsupported DOCKER_HOST scheme %q (use unix:// or tcp://)", u.Scheme)
+	}
+}
+
+// PublishHost returns the host on which ports published by the daemon are
+// reachable: the daemon's host for a tcp:// DOCKER_HOST, else 127.0.0.1.
+func (c *Client) PublishHost() string {
+	if c.publishHost == "" {
+		return "127.0.0.1"
+	}
+	return c.publishHost
+}
+
+// NewClientWithHTTP returns a client sending requests to baseURL (for
+// example an httptest server) with hc.
+func NewClientWithHTTP(baseURL string, hc *http.Client) *Client {
+	return &Client{
+		http:    hc,
+		baseURL: strings.TrimSuffix(baseURL, "/") + "/" + apiVersion,
+	}
+}
+
+// APIError is returned for any non-2xx response from the daemon.
+type APIError struct {
+	StatusCode int
+	Method     string
+	Path       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("docker API %s %s: %d: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the daemon (no such
+// container, network, image, ...).
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409 from the daemon, e.g. a container
+// name that is already in use.
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// do sends a request and returns the response if it has a 2xx or 304
+// status. The caller must close the body.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("encoding %s %s request: %w", method, path, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker API %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		defer resp.Body.Close()
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Method:     method,
+			Path:       path,
+			Message:    errorMessage(resp.Body),
+		}
+	}
+	return resp, nil
+}
+
+// doJSON sends a request and decodes a JSON response into out (if non-nil).
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := c.do(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// errorMessage extracts the daemon's {"message": "..."} error body.
+func errorMessage(r io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(r, 64<<10))
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil && body.Message != "" {
+		return body.Message
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// labelFilters encodes label selectors as the filters query parameter of
+// the list endpoints.
+func labelFilters(labels []string) (url.Values, error) {
+	query := url.Values{}
+	if len(labels) == 0 {
+		return query, nil
+	}
+	filters, err := json.Marshal(map[string][]string{"label": labels})
+	if err != nil {
+		return nil, err
+	}
+	query.Set("filters", string(filters))
+	return query, nil
+}
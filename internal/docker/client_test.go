@@ -0,0 +1,269 @@
+package docker
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDaemon serves the given Engine API routes (without the version
+// prefix) and records every request as "METHOD /path?query".
+type fakeDaemon struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func newFakeDaemon(t *testing.T, routes map[string]http.HandlerFunc) (*Client, *fakeDaemon) {
+	t.Helper()
+	d := &fakeDaemon{}
+	mux := http.NewServeMux()
+	for pattern, h := range routes {
+		mux.HandleFunc(pattern, h)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/"+apiVersion)
+		d.mu.Lock()
+		req := r.Method + " " + r.URL.Path
+		if r.URL.RawQuery != "" {
+			req += "?" + r.URL.RawQuery
+		}
+		d.requests = append(d.requests, req)
+		d.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return NewClientWithHTTP(ts.URL, ts.Client()), d
+}
+
+// received reports whether a request starting with prefix was made.
+func (d *fakeDaemon) received(prefix string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, r := range d.requests {
+		if strings.HasPrefix(r, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// frame encodes one frame of the multiplexed exec/logs stream.
+func frame(stream byte, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+func TestAPIError(t *testing.T) {
+	client, _ := newFakeDaemon(t, map[string]http.HandlerFunc{
+		"GET /containers/missing/json": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"No such container: missing"}`)
+		},
+		"POST /containers/create": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"message":"name already in use"}`)
+		},
+		"DELETE /networks/pgnet": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "daemon exploded", http.StatusInternalServerError)
+		},
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		call     func() error
+		status   int
+		message  string
+		notFound bool
+		conflict bool
+	}{
+		{
+			name:     "not found",
+			call:     func() error { _, err := client.InspectContainer(ctx, "missing"); return err },
+			status:   http.StatusNotFound,
+			message:  "No such container: missing",
+			notFound: true,
+		},
+		{
+			name:     "conflict",
+			call:     func() error { _, err := client.CreateContainer(ctx, "pg-primary", ContainerConfig{}); return err },
+			status:   http.StatusConflict,
+			message:  "name already in use",
+			conflict: true,
+		},
+		{
+			name:    "plain text body",
+			call:    func() error { return client.RemoveNetwork(ctx, "pgnet") },
+			status:  http.StatusInternalServerError,
+			message: "daemon exploded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error %v is not an *APIError", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Message != tt.message {
+				t.Errorf("got status %d message %q, want %d %q", apiErr.StatusCode, apiErr.Message, tt.status, tt.message)
+			}
+			if IsNotFound(err) != tt.notFound {
+				t.Errorf("IsNotFound = %t, want %t", IsNotFound(err), tt.notFound)
+			}
+			if IsConflict(err) != tt.conflict {
+				t.Errorf("IsConflict = %t, want %t", IsConflict(err), tt.conflict)
+			}
+			// Wrapping keeps the classification.
+			if IsNotFound(fmt.Errorf("wrapped: %w", err)) != tt.notFound {
+				t.Error("IsNotFound does not see through wrapping")
+			}
+		})
+	}
+}
+
+func TestExec(t *testing.T) {
+	tests := []struct {
+		name       string
+		stream     []byte
+		exitCode   int
+		wantStdout string
+		wantStderr string
+		wantErr    bool
+	}{
+		{
+			name: "interleaved streams",
+			stream: append(append(frame(1, "hello\n"), frame(2, "warning: x\n")...),
+				frame(1, "world\n")...),
+			exitCode:   0,
+			wantStdout: "hello\nworld\n",
+			wantStderr: "warning: x\n",
+		},
+		{
+			name:       "non-zero exit is not an error",
+			stream:     frame(2, "psql: error\n"),
+			exitCode:   2,
+			wantStderr: "psql: error\n",
+		},
+		{
+			name:     "empty output",
+			exitCode: 1,
+		},
+		{
+			name:    "truncated frame",
+			stream:  frame(1, "hello")[:10],
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newFakeDaemon(t, map[string]http.HandlerFunc{
+				"POST /containers/pg-primary/exec": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, `{"Id":"exec1"}`)
+				},
+				"POST /exec/exec1/start": func(w http.ResponseWriter, r *http.Request) {
+					w.Write(tt.stream)
+				},
+				"GET /exec/exec1/json": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintf(w, `{"Running":false,"ExitCode":%d}`, tt.exitCode)
+				},
+			})
+			res, err := client.Exec(context.Background(), "pg-primary", ExecOptions{Cmd: []string{"true"}})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.ExitCode != tt.exitCode || res.Stdout != tt.wantStdout || res.Stderr != tt.wantStderr {
+				t.Errorf("got %+v, want exit %d stdout %q stderr %q", res, tt.exitCode, tt.wantStdout, tt.wantStderr)
+			}
+		})
+	}
+}
+
+// runRoutes returns the routes of a one-off container run whose start is
+// handled by start.
+func runRoutes(start http.HandlerFunc) map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"POST /containers/create": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"Id":"c1"}`)
+		},
+		"POST /containers/c1/start": start,
+		"POST /containers/c1/wait": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"StatusCode":3}`)
+		},
+		"GET /containers/c1/logs": func(w http.ResponseWriter, r *http.Request) {
+			w.Write(frame(1, "tps = 100\n"))
+		},
+		"DELETE /containers/c1": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		},
+	}
+}
+
+func TestRunRemovesContainer(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		client, d := newFakeDaemon(t, runRoutes(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		res, err := client.Run(context.Background(), "pgbench", ContainerConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.ExitCode != 3 || res.Output != "tps = 100\n" {
+			t.Errorf("got %+v", res)
+		}
+		if !d.received("DELETE /containers/c1?force=true&v=true") {
+			t.Errorf("container not force-removed with its volumes; requests: %v", d.requests)
+		}
+	})
+
+	t.Run("start fails", func(t *testing.T) {
+		client, d := newFakeDaemon(t, runRoutes(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"message":"port is already allocated"}`, http.StatusInternalServerError)
+		}))
+		if _, err := client.Run(context.Background(), "pgbench", ContainerConfig{}); err == nil {
+			t.Fatal("expected an error")
+		}
+		if !d.received("DELETE /containers/c1") {
+			t.Errorf("container left behind after a failed start; requests: %v", d.requests)
+		}
+	})
+
+	t.Run("context cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		routes := runRoutes(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+		// The wait blocks until the client gives up.
+		routes["POST /containers/c1/wait"] = func(w http.ResponseWriter, r *http.Request) {
+			cancel()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			io.WriteString(w, `{"StatusCode":0}`)
+		}
+		client, d := newFakeDaemon(t, routes)
+		if _, err := client.Run(ctx, "pgbench", ContainerConfig{}); !errors.Is(err, context.Canceled) {
+			t.Fatalf("error %v, want context.Canceled", err)
+		}
+		if !d.received("DELETE /containers/c1") {
+			t.Errorf("container left behind after cancellation; requests: %v", d.requests)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// CreateContainer creates a container named name and returns its ID.
+func (c *Client) CreateContainer(ctx context.Context, name string, cfg ContainerConfig) (string, error) {
+	var resp struct {
+		ID string `json:"Id"`
+	}
+	query := url.Values{}
+	if name != "" {
+		query.Set("name", name)
+	}
+	if err := c.doJSON(ctx, "POST", "/containers/create", query, cfg, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// StartContainer starts a container. Starting a running container is not an error.
+func (c *Client) StartContainer(ctx context.Context, id string) error {
+	return c.doJSON(ctx, "POST", "/containers/"+url.PathEscape(id)+"/start", nil, nil, nil)
+}
+
+// StopContainer stops a container, killing it after timeoutSeconds.
+// Stopping a stopped container is not an error.
+func (c *Client) StopContainer(ctx context.Context, id string, timeoutSeconds int) error {
+	query := url.Values{"t": {strconv.Itoa(timeoutSeconds)}}
+	return c.doJSON(ctx, "POST", "/containers/"+url.PathEscape(id)+"/stop", query, nil, nil)
+}
+
+// RemoveContainer removes a container. With force a running container is
+// killed first; with volumes its anonymous volumes are removed too.
+func (c *Client) RemoveContainer(ctx context.Context, id string, force, volumes bool) error {
+	query := url.Values{
+		"force": {strconv.FormatBool(force)},
+		"v":     {strconv.FormatBool(volumes)},
+	}
+	return c.doJSON(ctx, "DELETE", "/containers/"+url.PathEscape(id), query, nil, nil)
+}
+
+// InspectContainer returns low-level information about a container.
+func (c *Client) InspectContainer(ctx context.Context, id string) (*ContainerInfo, error) {
+	var info ContainerInfo
+	if err := c.doJSON(ctx, "GET", "/containers/"+url.PathEscape(id)+"/json", nil, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// WaitContainer blocks until the container stops and returns its exit code.
+func (c *Client) WaitContainer(ctx context.Context, id string) (int, error) {
+	var resp struct {
+		StatusCode int `json:"StatusCode"`
+		Error      *struct {
+			Message string `json:"Message"`
+		} `json:"Error"`
+	}
+	if err := c.doJSON(ctx, "POST", "/containers/"+url.PathEscape(id)+"/wait", nil, nil, &resp); err != nil {
+		return 0, err
+	}
+	if resp.Error != nil && resp.Error.Message != "" {
+		return resp.StatusCode, fmt.Errorf("waiting for container %s: %s", id, resp.Error.Message)
+	}
+	return resp.StatusCode, nil
+}
+
+// ContainerLogs returns the combined stdout and stderr of a container. With
+// tail > 0 only the last tail lines are returned.
+func (c *Client) ContainerLogs(ctx context.Context, id string, tail int) (string, error) {
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if tail > 0 {
+		query.Set("tail", strconv.Itoa(tail))
+	}
+	resp, err := c.do(ctx, "GET", "/containers/"+url.PathEscape(id)+"/logs", query, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var out bytes.Buffer
+	if err := demux(resp.Body, &out, &out); err != nil {
+		return out.String(), fmt.Errorf("reading logs of %s: %w", id, err)
+	}
+	return out.String(), nil
+}
+
+// Run creates and starts a one-off container, waits for it to exit, collects
+// its output and removes it. A non-zero exit code is reported in the result,
+// not as an error.
+func (c *Client) Run(ctx context.Context, name string, cfg ContainerConfig) (*RunResult, error) {
+	id, err := c.CreateContainer(ctx, name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// Use a fresh context so cleanup still happens after cancellation.
+		_ = c.RemoveContainer(context.Background(), id, true, true)
+	}()
+
+	if err := c.StartContainer(ctx, id); err != nil {
+		return nil, err
+	}
+	code, err := c.WaitContainer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	output, err := c.ContainerLogs(ctx, id, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &RunResult{ExitCode: code, Output: output}, nil
+}
+
+// ListContainers returns containers (stopped ones too with all) carrying
+// every given label. A label without "=" matches any value.
+func (c *Client) ListContainers(ctx context.Context, all bool, labels ...string) ([]ContainerSummary, error) {
+	query, err := labelFilters(labels)
+	if err != nil {
+		return nil, err
+	}
+	query.Set("all", strconv.FormatBool(all))
+	var list []ContainerSummary
+	if err := c.doJSON(ctx, "GET", "/containers/json", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
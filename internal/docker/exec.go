@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// Exec runs a command inside a running container and waits for it to finish.
+// A non-zero exit code is reported in the result, not as an error.
+func (c *Client) Exec(ctx context.Context, container string, opts ExecOptions) (*ExecResult, error) {
+	create := struct {
+		Cmd          []string `json:"Cmd"`
+		User         string   `json:"User,omitempty"`
+		Env          []string `json:"Env,omitempty"`
+		AttachStdout bool     `json:"AttachStdout"`
+		AttachStderr bool     `json:"AttachStderr"`
+	}{
+		Cmd:          opts.Cmd,
+		User:         opts.User,
+		Env:          opts.Env,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := c.doJSON(ctx, "POST", "/containers/"+url.PathEscape(container)+"/exec", nil, create, &created); err != nil {
+		return nil, err
+	}
+
+	// Without a TTY the attached output is a multiplexed stream that ends
+	// when the command exits.
+	start := map[string]bool{"Detach": false, "Tty": false}
+	resp, err := c.do(ctx, "POST", "/exec/"+created.ID+"/start", nil, start)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	err = demux(resp.Body, &stdout, &stderr)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading exec output in %s: %w", container, err)
+	}
+
+	var inspect struct {
+		Running  bool `json:"Running"`
+		ExitCode int  `json:"ExitCode"`
+	}
+	if err := c.doJSON(ctx, "GET", "/exec/"+created.ID+"/json", nil, nil, &inspect); err != nil {
+		return nil, err
+	}
+	return &ExecResult{
+		ExitCode: inspect.ExitCode,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+	}, nil
+}
+
+// demux splits Docker's multiplexed stdout/stderr stream. Each frame has an
+// 8-byte header: stream type (1 = stdout, 2 = stderr), three zero bytes and
+// a big-endian uint32 payload size.
+func demux(r io.Reader, stdout, stderr io.Writer) error {
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+
+		dst := stdout
+		if header[0] == 2 {
+			dst = stderr
+		}
+		if _, err := io.CopyN(dst, r, size); err != nil {
+			return err
+		}
+	}
+}
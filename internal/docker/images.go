@@ -0,0 +1,53 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// ImageExists reports whether the image is present locally.
+func (c *Client) ImageExists(ctx context.Context, image string) (bool, error) {
+	err := c.doJSON(ctx, "GET", "/images/"+image+"/json", nil, nil, nil)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// PullImage pulls an image reference (with optional tag or digest) and
+// waits for the pull to finish.
+func (c *Client) PullImage(ctx context.Context, image string) error {
+	resp, err := c.do(ctx, "POST", "/images/create", url.Values{"fromImage": {image}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The daemon streams JSON progress messages; failures mid-pull arrive
+	// as a message with an error field rather than an HTTP status.
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			return fmt.Errorf("reading pull progress for %s: %w", image, err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("pulling %s: %s", image, msg.Error)
+		}
+	}
+	return nil
+}
+
+// EnsureImage pulls the image unless it is already present.
+func (c *Client) EnsureImage(ctx context.Context, image string) error {
+	ok, err := c.ImageExists(ctx, image)
+	if err != nil || ok {
+		return err
+	}
+	fmt.Printf("Pulling image %s\n", image)
+	return c.PullImage(ctx, image)
+}
@@ -0,0 +1,55 @@
+package docker
+
+import (
+	"context"
+	"net/url"
+)
+
+// InspectNetwork returns information about a network by name or ID.
+func (c *Client) InspectNetwork(ctx context.Context, name string) (*NetworkInfo, error) {
+	var info NetworkInfo
+	if err := c.doJSON(ctx, "GET", "/networks/"+url.PathEscape(name), nil, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// CreateNetwork creates a bridge network and returns its ID.
+func (c *Client) CreateNetwork(ctx context.Context, name string, labels map[string]string) (string, error) {
+	body := struct {
+		Name           string            `json:"Name"`
+		Driver         string            `json:"Driver"`
+		CheckDuplicate bool              `json:"CheckDuplicate"`
+		Labels         map[string]string `json:"Labels,omitempty"`
+	}{
+		Name:           name,
+		Driver:         "bridge",
+		CheckDuplicate: true,
+		Labels:         labels,
+	}
+	var resp struct {
+		ID string `json:"Id"`
+	}
+	if err := c.doJSON(ctx, "POST", "/networks/create", nil, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// RemoveNetwork removes a network.
+func (c *Client) RemoveNetwork(ctx context.Context, name string) error {
+	return c.doJSON(ctx, "DELETE", "/networks/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// ListNetworks returns networks carrying every given label.
+func (c *Client) ListNetworks(ctx context.Context, labels ...string) ([]NetworkInfo, error) {
+	query, err := labelFilters(labels)
+	if err != nil {
+		return nil, err
+	}
+	var list []NetworkInfo
+	if err := c.doJSON(ctx, "GET", "/networks", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
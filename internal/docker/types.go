@@ -0,0 +1,141 @@
+package docker
+
+import "strings"
+
+// ContainerConfig is the body of a container create request. Only the fields
+// this project uses are modelled.
+type ContainerConfig struct {
+	Image        string              `json:"Image"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	User         string              `json:"User,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	HostConfig   HostConfig          `json:"HostConfig"`
+}
+
+// HostConfig holds the host-dependent part of a container's configuration.
+type HostConfig struct {
+	NetworkMode  string                   `json:"NetworkMode,omitempty"`
+	PortBindings map[string][]PortBinding `json:"PortBindings,omitempty"`
+	Binds        []string                 `json:"Binds,omitempty"`
+	VolumesFrom  []string                 `json:"VolumesFrom,omitempty"`
+	ShmSize      int64                    `json:"ShmSize,omitempty"`
+	Resources
+}
+
+// Resources are a container's CPU and memory limits. Zero means unlimited.
+type Resources struct {
+	NanoCPUs int64 `json:"NanoCpus,omitempty"`
+	Memory   int64 `json:"Memory,omitempty"`
+}
+
+// PortBinding maps a container port to a host port.
+type PortBinding struct {
+	HostIP   string `json:"HostIp,omitempty"`
+	HostPort string `json:"HostPort"`
+}
+
+// ContainerInfo is the subset of a container inspect response we read.
+type ContainerInfo struct {
+	ID    string         `json:"Id"`
+	Name  string         `json:"Name"`
+	Image string         `json:"Image"` // image ID
+	State ContainerState `json:"State"`
+
+	Config struct {
+		Image  string            `json:"Image"` // image reference as requested
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+
+	HostConfig HostConfig `json:"HostConfig"`
+}
+
+// ContainerSummary is one entry of a container list response.
+type ContainerSummary struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"` // with a leading "/"
+	Image  string            `json:"Image"`
+	Labels map[string]string `json:"Labels"`
+	State  string            `json:"State"`  // created, running, exited, ...
+	Status string            `json:"Status"` // human readable, e.g. "Up 2 hours"
+	Ports  []Port            `json:"Ports"`
+	Mounts []Mount           `json:"Mounts"`
+}
+
+// Name returns the container name without the leading slash.
+func (s ContainerSummary) Name() string {
+	if len(s.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(s.Names[0], "/")
+}
+
+// Port is a published container port.
+type Port struct {
+	PrivatePort int    `json:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort"`
+	Type        string `json:"Type"`
+}
+
+// Mount is a volume or bind mount of a container.
+type Mount struct {
+	Type        string `json:"Type"` // volume | bind
+	Name        string `json:"Name"` // volume name (volume mounts only)
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+}
+
+// ContainerState is the runtime state of a container.
+type ContainerState struct {
+	Status     string  `json:"Status"` // created, running, exited, ...
+	Running    bool    `json:"Running"`
+	ExitCode   int     `json:"ExitCode"`
+	Error      string  `json:"Error"`
+	StartedAt  string  `json:"StartedAt"`
+	FinishedAt string  `json:"FinishedAt"`
+	Health     *Health `json:"Health,omitempty"`
+}
+
+// Health is the healthcheck status of a container, if it defines one.
+type Health struct {
+	Status string `json:"Status"` // starting, healthy, unhealthy
+}
+
+// NetworkInfo is the subset of a network inspect response we read.
+type NetworkInfo struct {
+	ID     string            `json:"Id"`
+	Name   string            `json:"Name"`
+	Driver string            `json:"Driver"`
+	Labels map[string]string `json:"Labels"`
+	// Containers maps the ID of each attached container to its endpoint.
+	// Only inspect fills it in, not list.
+	Containers map[string]NetworkEndpoint `json:"Containers"`
+}
+
+// NetworkEndpoint is a container attached to a network.
+type NetworkEndpoint struct {
+	Name string `json:"Name"`
+}
+
+// ExecOptions describes a command to run inside a running container.
+type ExecOptions struct {
+	Cmd  []string
+	User string
+	Env  []string
+}
+
+// ExecResult is the outcome of an exec.
+type ExecResult struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+// RunResult is the outcome of a one-off container run.
+type RunResult struct {
+	ExitCode int
+	// Output is the combined stdout and stderr of the container.
+	Output string
+}
@@ -0,0 +1,14 @@
+package docker
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// RemoveVolume removes a named volume. With force set, Docker removes it
+// even if it is still referenced.
+func (c *Client) RemoveVolume(ctx context.Context, name string, force bool) error {
+	query := url.Values{"force": {strconv.FormatBool(force)}}
+	return c.doJSON(ctx, "DELETE", "/volumes/"+url.PathEscape(name), query, nil, nil)
+}
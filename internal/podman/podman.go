@@ -0,0 +1,170 @@
+// Package podman runs the podman CLI for the provisioning and benchmark
+// code. Podman is rootless and daemonless, so unlike the docker package
+// there is no API socket to talk to: every operation is one podman command.
+package podman
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Client runs podman commands.
+type Client struct {
+	binary string
+}
+
+// NewClient returns a client for the podman binary named by PODMAN_BINARY,
+// or the first podman on PATH.
+func NewClient() (*Client, error) {
+	name := os.Getenv("PODMAN_BINARY")
+	if name == "" {
+		name = "podman"
+	}
+	binary, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("finding podman binary: %w", err)
+	}
+	return &Client{binary: binary}, nil
+}
+
+// CommandError is returned when a podman command exits non-zero.
+type CommandError struct {
+	Args     []string
+	ExitCode int
+	Output   string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("podman %s: exit code %d: %s", e.Args[0], e.ExitCode, strings.TrimSpace(e.Output))
+}
+
+// ExitCode returns the exit code of a failed podman command, or -1 if err
+// is not a CommandError.
+func ExitCode(err error) int {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.ExitCode
+	}
+	return -1
+}
+
+// Run runs podman with args and returns its combined stdout and stderr.
+// env is added to the environment of the podman process; together with a
+// bare `-e NAME` flag it passes secrets to containers without putting them
+// on the command line.
+func (c *Client) Run(ctx context.Context, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, c.binary, args...)
+	cmd.Env = append(os.Environ(), env...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out.String(), &CommandError{Args: args, ExitCode: exitErr.ExitCode(), Output: out.String()}
+	}
+	if err != nil {
+		return out.String(), fmt.Errorf("running podman %s: %w", args[0], err)
+	}
+	return out.String(), nil
+}
+
+// Exists reports whether the object of kind (container, pod, volume) exists.
+func (c *Client) Exists(ctx context.Context, kind, name string) (bool, error) {
+	_, err := c.Run(ctx, nil, kind, "exists", name)
+	if err == nil {
+		return true, nil
+	}
+	// podman <kind> exists exits 1 for a missing object and 125 on errors.
+	if ExitCode(err) == 1 {
+		return false, nil
+	}
+	return false, err
+}
+
+// Exec runs cmd inside a running container as user (empty: the image
+// default) and returns its output.
+func (c *Client) Exec(ctx context.Context, container, user string, cmd ...string) (string, error) {
+	args := []string{"exec"}
+	if user != "" {
+		args = append(args, "--user", user)
+	}
+	args = append(args, container)
+	return c.Run(ctx, nil, append(args, cmd...)...)
+}
+
+// Logs returns the last tail lines of a container's output.
+func (c *Client) Logs(ctx context.Context, container string, tail int) (string, error) {
+	return c.Run(ctx, nil, "logs", "--tail", strconv.Itoa(tail), container)
+}
+
+// ContainerRunning reports whether container exists and is running.
+func (c *Client) ContainerRunning(ctx context.Context, container string) (bool, error) {
+	out, err := c.Run(ctx, nil, "container", "inspect", "--format", "{{.State.Running}}", container)
+	if err != nil {
+		if ExitCode(err) == 125 && strings.Contains(err.Error(), "no such") {
+			return false, nil
+		}
+		return false, err
+	}
+	return strings.TrimSpace(out) == "true", nil
+}
+
+// StartContainer starts an existing container, and its pod's infra
+// container with it.
+func (c *Client) StartContainer(ctx context.Context, name string) error {
+	_, err := c.Run(ctx, nil, "start", name)
+	return err
+}
+
+// StopContainer stops a container, killing it after timeoutSeconds.
+// Stopping a stopped container is not an error.
+func (c *Client) StopContainer(ctx context.Context, name string, timeoutSeconds int) error {
+	_, err := c.Run(ctx, nil, "stop", "--time", strconv.Itoa(timeoutSeconds), name)
+	return err
+}
+
+// ListPods returns the names of pods carrying every one of labels
+// ("key=value").
+func (c *Client) ListPods(ctx context.Context, labels ...string) ([]string, error) {
+	args := []string{"pod", "ps", "--format", "{{.Name}}"}
+	for _, l := range labels {
+		args = append(args, "--filter", "label="+l)
+	}
+	out, err := c.Run(ctx, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// RemovePod force-removes a pod and all its containers. A missing pod is
+// not an error.
+func (c *Client) RemovePod(ctx context.Context, name string) error {
+	_, err := c.Run(ctx, nil, "pod", "rm", "--force", "--ignore", name)
+	return err
+}
+
+// RemoveContainer force-removes a container. A missing container is not an
+// error.
+func (c *Client) RemoveContainer(ctx context.Context, name string) error {
+	_, err := c.Run(ctx, nil, "rm", "--force", "--ignore", name)
+	return err
+}
+
+// RemoveVolume removes a named volume. A missing volume is not an error.
+func (c *Client) RemoveVolume(ctx context.Context, name string) error {
+	exists, err := c.Exists(ctx, "volume", name)
+	if err != nil || !exists {
+		return err
+	}
+	_, err = c.Run(ctx, nil, "volume", "rm", name)
+	return err
+}
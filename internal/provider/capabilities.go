@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+)
+
+// The interfaces below are implemented by providers of targets declaring
+// the matching capability, in addition to PostgresProvider. The CLI looks a
+// target up by capability and asserts the interface on its provider.
+
+// SlotLister is implemented by targets with CapSlots.
+type SlotLister interface {
+	// ListSlots returns all replication slots on the current primary
+	// together with the WAL they retain.
+	ListSlots() ([]SlotInfo, error)
+}
+
+// LagReporter is implemented by targets with CapLag.
+type LagReporter interface {
+	// ReplicationLag returns lag figures for every standby of the cluster.
+	ReplicationLag() ([]ReplicaLag, error)
+}
+
+// Failoverer is implemented by targets with CapFailover.
+type Failoverer interface {
+	// Failover fences the primary and promotes the replica to.
+	Failover(to string) error
+}
+
+// Switchoverer is implemented by targets with CapSwitchover.
+type Switchoverer interface {
+	// Switchover promotes the replica to and rejoins the old primary as
+	// a standby.
+	Switchover(to string) error
+}
+
+// Scaler is implemented by targets with CapScale.
+type Scaler interface {
+	// ScaleReplicas adds or removes replicas of a running cluster until
+	// it has n.
+	ScaleReplicas(cfg *config.Config, n int) error
+}
+
+// NodeRestarter is implemented by targets with CapRestart. Each method acts
+// on the named nodes, or the whole cluster when names is empty, and keeps
+// their data.
+type NodeRestarter interface {
+	StopNodes(names []string, opts StopOptions) error
+	StartNodes(names []string) ([]NodeRecovery, error)
+	RestartNodes(names []string, opts StopOptions) ([]NodeRecovery, error)
+}
+
+// ClusterLister is implemented by targets with CapClusters.
+type ClusterLister interface {
+	// ListClusters returns every cluster of the target sorted by name, not
+	// just the provider's own.
+	ListClusters() ([]ClusterSummary, error)
+}
+
+// SubscriberSyncer is implemented by providers supporting logical
+// replication. Subscribers only replicate tables that existed when their
+// subscription was last refreshed.
+type SubscriberSyncer interface {
+	// SyncSubscribers refreshes every subscription to pick up new tables.
+	SyncSubscribers() error
+}
+
+// OptionsProvisioner is implemented by providers whose provisioning honours
+// ProvisionOptions. Others only accept the defaults.
+type OptionsProvisioner interface {
+	ProvisionPostgresWithOptions(cfg *config.Config, opts ProvisionOptions) error
+}
+
+// ProvisionOptions are the provision flags of the CLI.
+type ProvisionOptions struct {
+	// Recreate allows replacing a node whose settings changed even though
+	// its data is not on a persistent volume and goes with the container.
+	Recreate bool
+}
+
+// SlotInfo describes a replication slot on the primary.
+type SlotInfo struct {
+	Name       string
+	Type       string // physical | logical
+	Active     bool
+	RestartLSN string // empty if the slot has never reserved WAL
+	// RetainedBytes is the amount of WAL the primary keeps for this slot,
+	// i.e. the distance from restart_lsn to the current WAL insert position.
+	RetainedBytes int64
+}
+
+// ReplicaLag reports how far a standby is behind, split into WAL it has not
+// received yet and WAL it has received but not replayed. The two differ a lot
+// on delayed standbys, which receive WAL promptly but hold back replay.
+type ReplicaLag struct {
+	Name       string
+	Upstream   string
+	ApplyDelay string // configured recovery_min_apply_delay, "" if none
+
+	ReceivedLSN string // pg_last_wal_receive_lsn(); "" before streaming starts
+	ReplayedLSN string // pg_last_wal_replay_lsn()
+
+	// ReceiveLagBytes is the distance from the primary's current WAL
+	// position to the received LSN.
+	ReceiveLagBytes int64
+	// ReplayLagBytes is the distance from the received to the replayed LSN.
+	ReplayLagBytes int64
+	// ReplayAge is the time since the last replayed transaction committed on
+	// the primary; negative if nothing has been replayed yet.
+	ReplayAge time.Duration
+}
+
+// ClusterSummary is one line of ListClusters.
+type ClusterSummary struct {
+	Name       string
+	Containers int
+	Running    int
+	// Primary is the primary node according to the state file, or the
+	// node labelled primary if there is no state file.
+	Primary string
+	// StateFile is the path of the cluster's state file, "" if it has none.
+	StateFile string
+}
+
+// NodeRecovery is how long a node took to come back after a start.
+type NodeRecovery struct {
+	Name string
+	Role string // RolePrimary or RoleReplica
+	// Ready is the time from starting the node until the server accepted
+	// connections, including any crash recovery. Zero for nodes that were
+	// not started.
+	Ready time.Duration
+	// Streaming is the time from the beginning of the start until the
+	// standby's WAL receiver streamed again, so it also covers reconnecting
+	// to a restarted upstream. Zero if not measured: logical mode, a node
+	// that is not a standby, or an upstream that is not running.
+	Streaming time.Duration
+}
+
+// StopOptions controls how StopNodes and RestartNodes stop nodes.
+type StopOptions struct {
+	// Immediate kills the servers without a shutdown checkpoint, so the
+	// next start goes through crash recovery. Data is kept either way.
+	Immediate bool
+}
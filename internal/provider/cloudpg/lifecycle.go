@@ -0,0 +1,86 @@
+package cloudpg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/cloud"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+)
+
+// Status asks the API for each instance recorded in the state.
+func (cp *CloudPostgresProvider) Status(ctx context.Context) (*provider.ClusterStatus, error) {
+	state, err := LoadState(cp.cluster)
+	if err != nil {
+		return nil, err
+	}
+	client := newClient(state.APIURL)
+	status := &provider.ClusterStatus{Cluster: cp.cluster}
+	if state.Failed() {
+		status.Error = state.Error
+	}
+	for _, name := range append([]string{state.Primary}, state.Replicas...) {
+		node := provider.NodeStatus{Name: name, Role: provider.RoleUnknown, State: provider.StateMissing}
+		inst, err := client.GetInstance(ctx, name)
+		if cloud.IsNotFound(err) {
+			status.Nodes = append(status.Nodes, node)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("getting instance %q: %w", name, err)
+		}
+		switch inst.Role {
+		case cloud.RolePrimary:
+			node.Role = provider.RolePrimary
+		case cloud.RoleReplica:
+			node.Role = provider.RoleReplica
+		}
+		switch inst.Status {
+		case cloud.StatusAvailable:
+			node.State = provider.StateRunning
+		case cloud.StatusCreating, cloud.StatusDeleting:
+			node.State = provider.StatePending
+		case cloud.StatusFailed:
+			node.State = provider.StateFailed
+		}
+		status.Nodes = append(status.Nodes, node)
+	}
+	return status, nil
+}
+
+// Endpoints returns the instance endpoints recorded in the state. The
+// service publishes one address per instance, used from everywhere.
+func (cp *CloudPostgresProvider) Endpoints(ctx context.Context) ([]provider.Endpoint, error) {
+	state, err := LoadState(cp.cluster)
+	if err != nil {
+		return nil, err
+	}
+	if state.Failed() {
+		return nil, fmt.Errorf("cluster provisioning failed (%s); run provision cloud again or destroy cloud", state.Error)
+	}
+	endpoints := make([]provider.Endpoint, 0, len(state.Replicas)+1)
+	add := func(name, role string) {
+		inst := state.Instances[name]
+		endpoints = append(endpoints, provider.Endpoint{
+			Node: name, Role: role,
+			Host: inst.Host, Port: inst.Port,
+			ExternalHost: inst.Host, ExternalPort: inst.Port,
+		})
+	}
+	add(state.Primary, provider.RolePrimary)
+	for _, name := range state.Replicas {
+		add(name, provider.RoleReplica)
+	}
+	return endpoints, nil
+}
+
+// Stop is not supported: the API has no way to stop an instance.
+func (cp *CloudPostgresProvider) Stop(ctx context.Context) error {
+	return fmt.Errorf("stopping cloud instances: %w", provider.ErrNotSupported)
+}
+
+// Start is not supported: the API has no way to stop, or start, an
+// instance.
+func (cp *CloudPostgresProvider) Start(ctx context.Context) error {
+	return fmt.Errorf("starting cloud instances: %w", provider.ErrNotSupported)
+}
@@ -0,0 +1,309 @@
+// Package cloudpg provisions the cluster as managed instances through the
+// HTTP API in package cloud: the primary is an instance, and each replica a
+// read replica of its upstream. Servers, networks and pg_hba.conf are the
+// control plane's business.
+package cloudpg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/cloud"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
+)
+
+// Labels put on every instance, so a cluster can be found through the API.
+const (
+	labelCluster = "pg-telemetry-lab.cluster"
+	labelRole    = "pg-telemetry-lab.role"
+)
+
+// deleteTimeout bounds how long destroy waits for each instance to go away.
+const deleteTimeout = 30 * time.Minute
+
+var _ provider.PostgresProvider = (*CloudPostgresProvider)(nil)
+
+type CloudPostgresProvider struct {
+	// cluster selects the state file and the instance labels.
+	cluster string
+}
+
+// NewCloudPostgresProvider returns a provider managing the named cluster.
+// An empty cluster means config.DefaultCluster. The API is taken from the
+// config when provisioning and from the state afterwards.
+func NewCloudPostgresProvider(cluster string) *CloudPostgresProvider {
+	if cluster == "" {
+		cluster = config.DefaultCluster
+	}
+	return &CloudPostgresProvider{cluster: cluster}
+}
+
+// newClient returns a client for apiURL authenticating with CLOUD_API_TOKEN.
+func newClient(apiURL string) *cloud.Client {
+	return cloud.NewClient(apiURL, os.Getenv("CLOUD_API_TOKEN"))
+}
+
+// ProvisionPostgres creates the primary instance and a read replica per
+// configured replica, each from its upstream, and waits until all are
+// available. Instances that already exist are kept, and instances of the
+// cluster that are no longer configured are deleted, so running it twice
+// is a no-op.
+//
+// Settings a managed service does not expose (apply_delay, synchronous
+// standbys, hba) are rejected rather than silently dropped; Docker-only
+// settings (image, network, volumes, resources) are ignored.
+func (cp *CloudPostgresProvider) ProvisionPostgres(cfg *config.Config) error {
+	ctx := context.Background()
+	if cfg.ClusterName() != cp.cluster {
+		return fmt.Errorf("config is for cluster %q but the provider manages %q", cfg.ClusterName(), cp.cluster)
+	}
+	if err := checkSupported(cfg); err != nil {
+		return err
+	}
+	nodes, err := cfg.OrderedReplicaNodes()
+	if err != nil {
+		return fmt.Errorf("ordering replicas: %w", err)
+	}
+
+	state := State{
+		Cluster:      cp.cluster,
+		APIURL:       cfg.Cloud.APIURL,
+		PollInterval: cfg.CloudPollInterval().String(),
+		User:         cfg.Postgres.Primary.User,
+		Database:     cfg.Postgres.Primary.Database,
+		Primary:      cfg.Postgres.Primary.HostName,
+		Instances:    make(map[string]Instance, len(nodes)+1),
+	}
+	if err := cp.provision(ctx, newClient(cfg.Cloud.APIURL), cfg, nodes, &state); err != nil {
+		state.Status = StatusFailed
+		state.Error = err.Error()
+		if saveErr := SaveState(cp.cluster, state); saveErr != nil {
+			return fmt.Errorf("%w (saving partial cloud state: %v)", err, saveErr)
+		}
+		return fmt.Errorf("%w; run provision cloud again or destroy cloud", err)
+	}
+
+	state.Status = StatusReady
+	if err := SaveState(cp.cluster, state); err != nil {
+		return fmt.Errorf("saving cloud state: %w", err)
+	}
+	return nil
+}
+
+// checkSupported rejects configs the cloud target cannot honour.
+func checkSupported(cfg *config.Config) error {
+	if cfg.Cloud.APIURL == "" {
+		return fmt.Errorf("cloud.api_url must be set for the cloud target")
+	}
+	if cfg.ReplicationMode() != config.ReplicationPhysical {
+		return fmt.Errorf("the cloud target supports %q replication only, got %q",
+			config.ReplicationPhysical, cfg.ReplicationMode())
+	}
+	if cfg.SynchronousStandbyNames() != "" {
+		return fmt.Errorf("the cloud target does not support synchronous_standby_names")
+	}
+	if len(cfg.PrimaryHBA()) > 0 {
+		return fmt.Errorf("the cloud target does not support hba rules; the service controls access")
+	}
+	for _, node := range cfg.ReplicaNodes() {
+		if node.ApplyDelay != "" {
+			return fmt.Errorf("replica %q: the cloud target does not support apply_delay", node.Name)
+		}
+		if len(cfg.ReplicaHBA(node)) > 0 {
+			return fmt.Errorf("replica %q: the cloud target does not support hba rules; the service controls access", node.Name)
+		}
+	}
+	return nil
+}
+
+// provision creates the missing instances, waits for all of them and
+// deletes the ones no longer configured, recording each in state.
+func (cp *CloudPostgresProvider) provision(ctx context.Context, client *cloud.Client, cfg *config.Config,
+	nodes []config.ReplicaNode, state *State) error {
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return err
+	}
+	interval, timeout := cfg.CloudPollInterval(), cfg.ReadinessTimeout()
+
+	primary := cfg.Postgres.Primary.HostName
+	err = cp.ensureInstance(ctx, client, state, primary, "", interval, timeout, func() error {
+		_, err := client.CreateInstance(ctx, cloud.CreateInstanceRequest{
+			Name:           primary,
+			Region:         cfg.Cloud.Region,
+			InstanceClass:  cfg.Cloud.InstanceClass,
+			EngineVersion:  cfg.Cloud.EngineVersion,
+			StorageGB:      cfg.Cloud.StorageGB,
+			Database:       cfg.Postgres.Primary.Database,
+			MasterUser:     cfg.Postgres.Primary.User,
+			MasterPassword: pw,
+			Parameters:     cfg.PrimaryParameters(),
+			Labels:         cp.labels(cloud.RolePrimary),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("primary instance %q: %w", primary, err)
+	}
+
+	// Upstreams come first, so each source is available before its
+	// replicas are requested.
+	for _, node := range nodes {
+		state.Replicas = append(state.Replicas, node.Name)
+		err := cp.ensureInstance(ctx, client, state, node.Name, node.Upstream, interval, timeout, func() error {
+			_, err := client.CreateReadReplica(ctx, node.Upstream, cloud.CreateReplicaRequest{
+				Name:          node.Name,
+				Region:        cfg.Cloud.Region,
+				InstanceClass: cfg.Cloud.InstanceClass,
+				Parameters:    cfg.ReplicaParameters(node),
+				Labels:        cp.labels(cloud.RoleReplica),
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("replica instance %q: %w", node.Name, err)
+		}
+	}
+
+	return cp.removeOrphans(ctx, client, cfg, interval)
+}
+
+// labels returns the labels of an instance of the cluster.
+func (cp *CloudPostgresProvider) labels(role string) map[string]string {
+	return map[string]string{labelCluster: cp.cluster, labelRole: role}
+}
+
+// ensureInstance creates the instance with create unless it exists, waits
+// until it is available and records its endpoint. An existing instance
+// must replicate from source ("" for the primary).
+func (cp *CloudPostgresProvider) ensureInstance(ctx context.Context, client *cloud.Client, state *State,
+	name, source string, interval, timeout time.Duration, create func() error) error {
+	inst, err := client.GetInstance(ctx, name)
+	switch {
+	case cloud.IsNotFound(err):
+		fmt.Printf("Creating instance %s\n", name)
+		if err := create(); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case inst.Labels[labelCluster] != cp.cluster:
+		return fmt.Errorf("an instance with this name exists outside cluster %q", cp.cluster)
+	case inst.Source != source:
+		return fmt.Errorf("instance exists but replicates from %q, not %q; destroy cloud first", inst.Source, source)
+	case inst.Status == cloud.StatusDeleting:
+		return fmt.Errorf("instance is being deleted; retry once it is gone")
+	default:
+		fmt.Printf("Instance %s already exists (%s)\n", name, inst.Status)
+	}
+
+	role := cloud.RolePrimary
+	if source != "" {
+		role = cloud.RoleReplica
+	}
+	// Recorded before waiting: the instance exists even if it never
+	// becomes available.
+	state.Instances[name] = Instance{Role: role, Source: source}
+
+	fmt.Printf("Waiting for %s to become available...\n", name)
+	inst, err = client.WaitAvailable(ctx, name, interval, timeout)
+	if err != nil {
+		return err
+	}
+	if inst.Endpoint != nil {
+		state.Instances[name] = Instance{Role: role, Source: source, Host: inst.Endpoint.Host, Port: inst.Endpoint.Port}
+	}
+	return nil
+}
+
+// removeOrphans deletes the cluster's instances that are no longer in the
+// config, replicas of replicas first.
+func (cp *CloudPostgresProvider) removeOrphans(ctx context.Context, client *cloud.Client, cfg *config.Config, interval time.Duration) error {
+	list, err := client.ListInstances(ctx, labelCluster+"="+cp.cluster)
+	if err != nil {
+		return fmt.Errorf("listing instances: %w", err)
+	}
+	desired := append(cfg.ReplicaNames(), cfg.Postgres.Primary.HostName)
+	var orphans []cloud.Instance
+	for _, inst := range list {
+		if !slices.Contains(desired, inst.Name) {
+			orphans = append(orphans, inst)
+		}
+	}
+	return deleteInstances(ctx, client, orphans, interval)
+}
+
+// DestroyPostgres deletes every instance of the cluster, found by label
+// through the API recorded in the state. Replicas are deleted, and gone,
+// before their sources, since the API refuses to delete an instance that
+// still has replicas.
+func (cp *CloudPostgresProvider) DestroyPostgres() error {
+	ctx := context.Background()
+	state, err := LoadState(cp.cluster)
+	if err != nil {
+		return err
+	}
+	client := newClient(state.APIURL)
+	list, err := client.ListInstances(ctx, labelCluster+"="+cp.cluster)
+	if err != nil {
+		return fmt.Errorf("listing instances: %w", err)
+	}
+	if err := deleteInstances(ctx, client, list, state.pollInterval()); err != nil {
+		return err
+	}
+	_ = os.Remove(StatePath(cp.cluster))
+	return nil
+}
+
+// deleteInstances deletes instances leaves first, waiting for each to be
+// gone before deleting its source.
+func deleteInstances(ctx context.Context, client *cloud.Client, instances []cloud.Instance, interval time.Duration) error {
+	var errs []string
+	for _, inst := range deletionOrder(instances) {
+		fmt.Printf("Deleting instance %s\n", inst.Name)
+		if err := client.DeleteInstance(ctx, inst.Name); err != nil && !cloud.IsNotFound(err) {
+			errs = append(errs, fmt.Sprintf("deleting %q: %v", inst.Name, err))
+			continue
+		}
+		if err := client.WaitDeleted(ctx, inst.Name, interval, deleteTimeout); err != nil {
+			errs = append(errs, fmt.Sprintf("waiting for %q to be deleted: %v", inst.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors occurred while deleting instances: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// deletionOrder sorts instances so every instance comes before its source.
+func deletionOrder(instances []cloud.Instance) []cloud.Instance {
+	remaining := slices.Clone(instances)
+	ordered := make([]cloud.Instance, 0, len(remaining))
+	for len(remaining) > 0 {
+		// Pick instances no remaining instance replicates from.
+		var next, rest []cloud.Instance
+		for _, inst := range remaining {
+			isSource := slices.ContainsFunc(remaining, func(other cloud.Instance) bool {
+				return other.Source == inst.Name
+			})
+			if isSource {
+				rest = append(rest, inst)
+			} else {
+				next = append(next, inst)
+			}
+		}
+		if len(next) == 0 {
+			// A cycle cannot come from the API; delete the rest as-is.
+			return append(ordered, rest...)
+		}
+		ordered = append(ordered, next...)
+		remaining = rest
+	}
+	return ordered
+}
@@ -0,0 +1,241 @@
+package cloudpg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/cloud"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/cloud/fake"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+)
+
+// recorder wraps the fake control plane, recording which instances are
+// created in which order and failing the creation of one of them.
+type recorder struct {
+	next http.Handler
+
+	mu      sync.Mutex
+	created []string
+	failOn  string
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		// Both create requests carry the instance name in the body; read
+		// it and hand the body on unchanged.
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		var named struct{ Name string }
+		_ = json.Unmarshal(body, &named)
+		name := named.Name
+
+		r.mu.Lock()
+		fail := name == r.failOn
+		if !fail {
+			r.created = append(r.created, name)
+		}
+		r.mu.Unlock()
+		if fail {
+			http.Error(w, `{"message":"injected failure"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	r.next.ServeHTTP(w, req)
+}
+
+// startFake runs the fake control plane with a clock that moves one second
+// per request, so instances pass through "creating" and "deleting" for a
+// couple of polls without the test sleeping.
+func startFake(t *testing.T) (*recorder, string) {
+	t.Helper()
+	server := fake.NewServer()
+	server.Delay = 2 * time.Second
+	var mu sync.Mutex
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	server.Now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(time.Second)
+		return now
+	}
+	rec := &recorder{next: server}
+	ts := httptest.NewServer(rec)
+	t.Cleanup(ts.Close)
+	return rec, ts.URL
+}
+
+// setup runs the test in an empty directory, since state files are
+// written relative to it, with the password the provider requires.
+func setup(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("PG_PASSWORD", "secret")
+	t.Setenv("CLOUD_API_TOKEN", "")
+}
+
+// loadConfig writes a config with the given replica nodes and loads it.
+func loadConfig(t *testing.T, apiURL, nodes string) *config.Config {
+	t.Helper()
+	yaml := `version: 1
+environment: "test"
+postgres:
+  image: "postgres:16"
+  network: "pgnet"
+  readiness_timeout: "1m"
+  primary:
+    name: "pg-primary"
+    port: 5432
+    database: "pgbench"
+    user: "postgres"
+  replicas:
+    nodes:
+` + nodes + `
+cloud:
+  api_url: "` + apiURL + `"
+  region: "test-1"
+  poll_interval: "1ms"
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	return cfg
+}
+
+const cascadingNodes = `      - name: "pg-replica-2"
+        port: 5541
+        upstream: "pg-replica-1"
+      - name: "pg-replica-1"
+        port: 5540
+`
+
+func listNames(t *testing.T, apiURL string) []string {
+	t.Helper()
+	list, err := cloud.NewClient(apiURL, "").ListInstances(context.Background(), labelCluster+"="+config.DefaultCluster)
+	if err != nil {
+		t.Fatalf("listing instances: %v", err)
+	}
+	var names []string
+	for _, inst := range list {
+		names = append(names, inst.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestProvisionCreatesUpstreamsFirst(t *testing.T) {
+	setup(t)
+	rec, apiURL := startFake(t)
+	cfg := loadConfig(t, apiURL, cascadingNodes)
+
+	cp := NewCloudPostgresProvider("")
+	if err := cp.ProvisionPostgres(cfg); err != nil {
+		t.Fatalf("provision: %v", err)
+	}
+
+	want := []string{"pg-primary", "pg-replica-1", "pg-replica-2"}
+	if !slices.Equal(rec.created, want) {
+		t.Errorf("created %v, want %v", rec.created, want)
+	}
+	state, err := LoadState(config.DefaultCluster)
+	if err != nil {
+		t.Fatalf("loading state: %v", err)
+	}
+	if state.Failed() {
+		t.Fatalf("state failed: %s", state.Error)
+	}
+	if got := state.Instances["pg-replica-2"]; got.Source != "pg-replica-1" || got.Host == "" || got.Port != 5432 {
+		t.Errorf("pg-replica-2 recorded as %+v", got)
+	}
+
+	// A second run keeps what exists.
+	if err := cp.ProvisionPostgres(cfg); err != nil {
+		t.Fatalf("second provision: %v", err)
+	}
+	if len(rec.created) != len(want) {
+		t.Errorf("second provision created %v", rec.created[len(want):])
+	}
+
+	if err := cp.DestroyPostgres(); err != nil {
+		t.Fatalf("destroy: %v", err)
+	}
+	if names := listNames(t, apiURL); len(names) != 0 {
+		t.Errorf("instances left after destroy: %v", names)
+	}
+	if _, err := LoadState(config.DefaultCluster); err != ErrNoState {
+		t.Errorf("state after destroy: %v, want ErrNoState", err)
+	}
+}
+
+func TestProvisionRemovesOrphans(t *testing.T) {
+	setup(t)
+	_, apiURL := startFake(t)
+	cp := NewCloudPostgresProvider("")
+
+	if err := cp.ProvisionPostgres(loadConfig(t, apiURL, cascadingNodes)); err != nil {
+		t.Fatalf("provision: %v", err)
+	}
+	// Both replicas go, pg-replica-2 first: the API refuses to delete
+	// pg-replica-1 while it still has a replica. pg-replica-3 is new.
+	shrunk := loadConfig(t, apiURL, `      - name: "pg-replica-3"
+        port: 5542
+`)
+	if err := cp.ProvisionPostgres(shrunk); err != nil {
+		t.Fatalf("provision after shrinking: %v", err)
+	}
+
+	want := []string{"pg-primary", "pg-replica-3"}
+	if names := listNames(t, apiURL); !slices.Equal(names, want) {
+		t.Errorf("instances %v, want %v", names, want)
+	}
+}
+
+func TestProvisionSavesFailedState(t *testing.T) {
+	setup(t)
+	rec, apiURL := startFake(t)
+	rec.failOn = "pg-replica-2"
+	cp := NewCloudPostgresProvider("")
+
+	err := cp.ProvisionPostgres(loadConfig(t, apiURL, cascadingNodes))
+	if err == nil || !strings.Contains(err.Error(), "pg-replica-2") {
+		t.Fatalf("provision error = %v, want one naming pg-replica-2", err)
+	}
+
+	state, err := LoadState(config.DefaultCluster)
+	if err != nil {
+		t.Fatalf("loading state: %v", err)
+	}
+	if !state.Failed() || !strings.Contains(state.Error, "injected failure") {
+		t.Errorf("state status %q error %q, want failed with the API error", state.Status, state.Error)
+	}
+	if _, ok := state.Instances["pg-replica-1"]; !ok {
+		t.Errorf("state does not record pg-replica-1, created before the failure")
+	}
+
+	// Destroy finds what was created by label.
+	if err := cp.DestroyPostgres(); err != nil {
+		t.Fatalf("destroy: %v", err)
+	}
+	if names := listNames(t, apiURL); len(names) != 0 {
+		t.Errorf("instances left after destroy: %v", names)
+	}
+}
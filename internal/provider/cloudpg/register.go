@@ -0,0 +1,26 @@
+package cloudpg
+
+import (
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+)
+
+// Target is the CLI name of this provider.
+const Target = "cloud"
+
+func init() {
+	provider.Register(provider.Target{
+		Name:         Target,
+		Title:        "Cloud",
+		Description:  "Create managed instances through the cloud API in cloud.api_url",
+		Capabilities: []string{provider.CapProvision, provider.CapDestroy, provider.CapStatus},
+		New: func(cluster string) (provider.PostgresProvider, error) {
+			return NewCloudPostgresProvider(cluster), nil
+		},
+		Destroy: func(cluster string, _ provider.DestroyOptions) (string, error) {
+			if err := NewCloudPostgresProvider(cluster).DestroyPostgres(); err != nil {
+				return "", err
+			}
+			return "instances deleted", nil
+		},
+	})
+}
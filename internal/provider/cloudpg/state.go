@@ -0,0 +1,108 @@
+package cloudpg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+)
+
+// StateDir holds one state file per cluster created through the cloud API.
+const StateDir = ".telemetry/cloud"
+
+// StatePath returns the state file of cluster.
+func StatePath(cluster string) string {
+	return filepath.Join(StateDir, cluster+".json")
+}
+
+// Provisioning outcomes recorded in State.Status.
+const (
+	StatusReady  = "ready"
+	StatusFailed = "failed"
+)
+
+// ErrNoState is returned by LoadState when nothing was provisioned yet.
+var ErrNoState = errors.New("no cloud state found, provision first")
+
+// Instance records a managed instance and where to reach it.
+type Instance struct {
+	Role   string `json:"role"`
+	Source string `json:"source,omitempty"`
+	Host   string `json:"host,omitempty"`
+	Port   int    `json:"port,omitempty"`
+}
+
+// State records a cluster of managed instances.
+type State struct {
+	Cluster string `json:"cluster"`
+	// APIURL is the control plane the instances were created through, so
+	// destroy does not need the config.
+	APIURL string `json:"api_url"`
+	// PollInterval is cloud.poll_interval, reused by destroy.
+	PollInterval string `json:"poll_interval,omitempty"`
+	User         string `json:"user"`
+	Database     string `json:"database"`
+
+	Primary  string   `json:"primary"`
+	Replicas []string `json:"replicas"`
+
+	// Instances maps instance name to its role, source and endpoint.
+	Instances map[string]Instance `json:"instances"`
+
+	CreatedAt string `json:"created_at"`
+
+	// Status is StatusFailed when provisioning stopped part way; Error
+	// holds the reason.
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// pollInterval returns the recorded poll interval, or the default.
+func (s *State) pollInterval() time.Duration {
+	d, err := time.ParseDuration(s.PollInterval)
+	if err != nil || d <= 0 {
+		return config.DefaultCloudPollInterval
+	}
+	return d
+}
+
+// Failed reports whether the last provisioning run did not complete.
+func (s *State) Failed() bool {
+	return s.Status == StatusFailed
+}
+
+// SaveState writes the state of cluster to disk.
+func SaveState(cluster string, state State) error {
+	path := StatePath(cluster)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+	if state.CreatedAt == "" {
+		state.CreatedAt = time.Now().Format(time.RFC3339)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadState reads the state of cluster from disk.
+func LoadState(cluster string) (*State, error) {
+	data, err := os.ReadFile(StatePath(cluster))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoState
+		}
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("unmarshal state: %w", err)
+	}
+	return &state, nil
+}
@@ -0,0 +1,83 @@
+package dockerpg
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+)
+
+// ListClusters returns every local cluster, found through container labels
+// and state files, sorted by name. It is not limited to the provider's own
+// cluster.
+func (dp *DockerPostgresProvider) ListClusters() ([]provider.ClusterSummary, error) {
+	ctx := context.Background()
+	containers, err := dp.client.ListContainers(ctx, true, pgsetup.LabelCluster)
+	if err != nil {
+		return nil, fmt.Errorf("listing labelled containers: %w", err)
+	}
+
+	clusters := make(map[string]*provider.ClusterSummary)
+	get := func(name string) *provider.ClusterSummary {
+		if c, ok := clusters[name]; ok {
+			return c
+		}
+		c := &provider.ClusterSummary{Name: name}
+		clusters[name] = c
+		return c
+	}
+	for _, c := range containers {
+		summary := get(c.Labels[pgsetup.LabelCluster])
+		summary.Containers++
+		if c.State == "running" {
+			summary.Running++
+		}
+		if c.Labels[pgsetup.LabelRole] == pgsetup.RolePrimary && (summary.Primary == "" || c.State == "running") {
+			summary.Primary = c.Name()
+		}
+	}
+
+	names, err := stateFileClusters()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		summary := get(name)
+		summary.StateFile = StatePath(name)
+		if state, err := LoadLocalState(name); err == nil {
+			summary.Primary = state.PrimaryContainer
+		}
+	}
+
+	list := make([]provider.ClusterSummary, 0, len(clusters))
+	for _, name := range slices.Sorted(maps.Keys(clusters)) {
+		list = append(list, *clusters[name])
+	}
+	return list, nil
+}
+
+// stateFileClusters returns the names of clusters that have a state file.
+func stateFileClusters() ([]string, error) {
+	var names []string
+	if _, err := os.Stat(LocalStatePath); err == nil {
+		names = append(names, config.DefaultCluster)
+	}
+	entries, err := os.ReadDir(ClustersStateDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading %s: %w", ClustersStateDir, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	return names, nil
+}
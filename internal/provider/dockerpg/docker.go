@@ -0,0 +1,227 @@
+package dockerpg
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/docker"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
+)
+
+// containerSpec describes a Postgres container to create. Every container
+// publishes the server port 5432 on HostPort.
+type containerSpec struct {
+	Name       string
+	Image      string
+	Network    string
+	HostPort   int
+	Env        []string
+	Entrypoint []string // empty keeps the image entrypoint
+	Cmd        []string
+	// Volume is mounted at the data directory: a named volume or an
+	// absolute host path. Empty leaves data in anonymous storage.
+	Volume string
+	Limits config.ResourceLimits
+	// Labels identify the node for discovery. They are not part of the spec
+	// hash, so a config change alone does not recreate the container.
+	Labels map[string]string
+}
+
+// config translates the spec into a Docker Engine API create request,
+// labelled with the node labels and the spec hash used for drift detection.
+func (s containerSpec) config() docker.ContainerConfig {
+	cfg := s.dockerConfig()
+	cfg.Labels = map[string]string{labelSpecHash: s.hash()}
+	maps.Copy(cfg.Labels, s.Labels)
+	return cfg
+}
+
+// dockerConfig is config without labels.
+func (s containerSpec) dockerConfig() docker.ContainerConfig {
+	var binds []string
+	if s.Volume != "" {
+		binds = []string{s.Volume + ":" + pgsetup.DataDir}
+	}
+	return docker.ContainerConfig{
+		Image:        s.Image,
+		Entrypoint:   s.Entrypoint,
+		Cmd:          s.Cmd,
+		Env:          s.Env,
+		ExposedPorts: map[string]struct{}{"5432/tcp": {}},
+		HostConfig: docker.HostConfig{
+			NetworkMode: s.Network,
+			PortBindings: map[string][]docker.PortBinding{
+				"5432/tcp": {{HostPort: strconv.Itoa(s.HostPort)}},
+			},
+			Binds:   binds,
+			ShmSize: s.Limits.ShmSizeBytes,
+			Resources: docker.Resources{
+				NanoCPUs: s.Limits.NanoCPUs,
+				Memory:   s.Limits.MemoryBytes,
+			},
+		},
+	}
+}
+
+// createContainer pulls the image if needed, then creates and starts the container.
+func (dp *DockerPostgresProvider) createContainer(ctx context.Context, spec containerSpec) error {
+	if err := dp.client.EnsureImage(ctx, spec.Image); err != nil {
+		return fmt.Errorf("ensuring image %q: %w", spec.Image, err)
+	}
+
+	fmt.Printf("Creating container %s (image %s, network %s, port %d, env %s)\n",
+		spec.Name, spec.Image, spec.Network, spec.HostPort, strings.Join(util.MaskArgs(spec.Env), " "))
+	id, err := dp.client.CreateContainer(ctx, spec.Name, spec.config())
+	if err != nil {
+		return fmt.Errorf("creating container %q: %w", spec.Name, err)
+	}
+	if err := dp.client.StartContainer(ctx, id); err != nil {
+		return fmt.Errorf("starting container %q: %w", spec.Name, err)
+	}
+	return nil
+}
+
+// removeContainer force-removes a container and its anonymous volumes.
+// A container that no longer exists is not an error.
+func (dp *DockerPostgresProvider) removeContainer(ctx context.Context, name string) error {
+	fmt.Printf("Removing container %s\n", name)
+	err := dp.client.RemoveContainer(ctx, name, true, true)
+	if docker.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// removeData deletes a node's persistent data directory. Named volumes are
+// removed; host paths are never deleted, only reported.
+func (dp *DockerPostgresProvider) removeData(ctx context.Context, volume string) error {
+	if config.IsHostPath(volume) {
+		fmt.Printf("Leaving data in host path %s\n", volume)
+		return nil
+	}
+	fmt.Printf("Removing volume %s\n", volume)
+	err := dp.client.RemoveVolume(ctx, volume, false)
+	if docker.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// removeNetworks removes the networks labelled with the cluster once no
+// container is attached to them any more. Networks created by hand carry
+// no label and are never removed.
+func (dp *DockerPostgresProvider) removeNetworks(ctx context.Context) error {
+	networks, err := dp.client.ListNetworks(ctx, pgsetup.LabelCluster+"="+dp.cluster)
+	if err != nil {
+		return fmt.Errorf("listing networks of cluster %q: %w", dp.cluster, err)
+	}
+	for _, n := range networks {
+		info, err := dp.client.InspectNetwork(ctx, n.Name)
+		if docker.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("inspecting network %q: %w", n.Name, err)
+		}
+		if len(info.Containers) > 0 {
+			fmt.Printf("Keeping network %s, %d containers still attached\n", n.Name, len(info.Containers))
+			continue
+		}
+		fmt.Printf("Removing network %s\n", n.Name)
+		if err := dp.client.RemoveNetwork(ctx, n.Name); err != nil && !docker.IsNotFound(err) {
+			return fmt.Errorf("removing network %q: %w", n.Name, err)
+		}
+	}
+	return nil
+}
+
+// stopContainer stops a container, giving the server timeoutSeconds to shut down.
+func (dp *DockerPostgresProvider) stopContainer(ctx context.Context, name string, timeoutSeconds int) error {
+	fmt.Printf("Stopping container %s\n", name)
+	return dp.client.StopContainer(ctx, name, timeoutSeconds)
+}
+
+// startContainer starts an existing container.
+func (dp *DockerPostgresProvider) startContainer(ctx context.Context, name string) error {
+	fmt.Printf("Starting container %s\n", name)
+	return dp.client.StartContainer(ctx, name)
+}
+
+// dockerExec runs a command inside a running container and returns its stdout.
+// Nothing is printed; callers decide what is worth logging.
+func (dp *DockerPostgresProvider) dockerExec(ctx context.Context, container string, cmdArgs ...string) (string, error) {
+	return dp.dockerExecAs(ctx, "", container, cmdArgs...)
+}
+
+// dockerExecAs is dockerExec running as the given user ("" keeps the
+// container default). A non-zero exit code is returned as an error carrying
+// the command's stderr.
+func (dp *DockerPostgresProvider) dockerExecAs(ctx context.Context, user, container string, cmdArgs ...string) (string, error) {
+	res, err := dp.client.Exec(ctx, container, docker.ExecOptions{Cmd: cmdArgs, User: user})
+	if err != nil {
+		return "", err
+	}
+	if res.ExitCode != 0 {
+		if msg := strings.TrimSpace(res.Stderr); msg != "" {
+			return res.Stdout, fmt.Errorf("%s exited with code %d: %s", cmdArgs[0], res.ExitCode, msg)
+		}
+		return res.Stdout, fmt.Errorf("%s exited with code %d", cmdArgs[0], res.ExitCode)
+	}
+	return res.Stdout, nil
+}
+
+// runHelper runs a one-off container with the data volume of a stopped
+// container mounted, as the postgres user, and returns its combined output.
+// It is how we operate on a data directory while its server is down.
+func (dp *DockerPostgresProvider) runHelper(ctx context.Context, state *LocalState, container string, cmdArgs ...string) (string, error) {
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return "", err
+	}
+	cfg := docker.ContainerConfig{
+		Image:      state.Image,
+		Entrypoint: cmdArgs[:1],
+		Cmd:        cmdArgs[1:],
+		User:       "postgres",
+		Env:        []string{"PGDATA=" + pgsetup.DataDir, "PGPASSWORD=" + pw},
+		HostConfig: docker.HostConfig{
+			NetworkMode: state.Network,
+			VolumesFrom: []string{container},
+		},
+	}
+	res, err := dp.client.Run(ctx, "", cfg)
+	if err != nil {
+		return "", err
+	}
+	if res.ExitCode != 0 {
+		return res.Output, fmt.Errorf("%s exited with code %d", cmdArgs[0], res.ExitCode)
+	}
+	return res.Output, nil
+}
+
+// ensureNetwork creates the bridge network unless it already exists and
+// reports whether it was created.
+func (dp *DockerPostgresProvider) ensureNetwork(ctx context.Context, network string) (bool, error) {
+	if network == "" {
+		return false, fmt.Errorf("postgres.network must be set in config")
+	}
+
+	_, err := dp.client.InspectNetwork(ctx, network)
+	if err == nil {
+		return false, nil
+	}
+	if !docker.IsNotFound(err) {
+		return false, err
+	}
+
+	fmt.Printf("Creating network %s\n", network)
+	if _, err := dp.client.CreateNetwork(ctx, network, map[string]string{pgsetup.LabelCluster: dp.cluster}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,53 @@
+package dockerpg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/docker"
+)
+
+func TestRemoveNetworks(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers map[string]docker.NetworkEndpoint
+		wantRemove bool
+	}{
+		{name: "unused network", wantRemove: true},
+		{name: "containers attached", containers: map[string]docker.NetworkEndpoint{"abc": {Name: "other"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filters string
+			removed := false
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /v1.41/networks", func(w http.ResponseWriter, r *http.Request) {
+				filters = r.URL.Query().Get("filters")
+				json.NewEncoder(w).Encode([]docker.NetworkInfo{{Name: "pg15-pgnet"}})
+			})
+			mux.HandleFunc("GET /v1.41/networks/pg15-pgnet", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(docker.NetworkInfo{Name: "pg15-pgnet", Containers: tt.containers})
+			})
+			mux.HandleFunc("DELETE /v1.41/networks/pg15-pgnet", func(w http.ResponseWriter, r *http.Request) {
+				removed = true
+				w.WriteHeader(http.StatusNoContent)
+			})
+			ts := httptest.NewServer(mux)
+			t.Cleanup(ts.Close)
+			dp := NewDockerPostgresProvider(docker.NewClientWithHTTP(ts.URL, ts.Client()), "pg15")
+
+			if err := dp.removeNetworks(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if filters != `{"label":["pg-telemetry-lab.cluster=pg15"]}` {
+				t.Errorf("networks listed with filters %s", filters)
+			}
+			if removed != tt.wantRemove {
+				t.Errorf("removed = %t, want %t", removed, tt.wantRemove)
+			}
+		})
+	}
+}
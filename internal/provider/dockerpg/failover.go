@@ -0,0 +1,148 @@
+package dockerpg
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
+)
+
+const (
+	// promoteWaitSeconds is how long pg_promote waits for promotion to finish.
+	promoteWaitSeconds = 60
+
+	// fenceTimeoutSeconds is how long a fenced primary gets to shut down
+	// before it is killed.
+	fenceTimeoutSeconds = 10
+)
+
+// Failover fences the current primary by stopping its container, promotes
+// the standby named target and repoints the remaining standbys at it.
+// The fenced container is kept (stopped) and recorded in the local state so
+// it can be inspected or destroyed later.
+func (dp *DockerPostgresProvider) Failover(target string) error {
+	ctx := context.Background()
+	state, err := LoadLocalState(dp.cluster)
+	if err != nil {
+		return fmt.Errorf("loading local state: %w", err)
+	}
+	if err := state.requireReady(); err != nil {
+		return err
+	}
+	if state.ReplicationMode == config.ReplicationLogical {
+		return fmt.Errorf("failover is only supported in %q replication mode", config.ReplicationPhysical)
+	}
+	if !slices.Contains(state.ReplicaContainers, target) {
+		return fmt.Errorf("%q is not a replica of this cluster (replicas: %v)", target, state.ReplicaContainers)
+	}
+
+	oldPrimary := state.PrimaryContainer
+	start := time.Now()
+
+	fmt.Printf("Fencing primary %s\n", oldPrimary)
+	if err := dp.stopContainer(ctx, oldPrimary, fenceTimeoutSeconds); err != nil {
+		return fmt.Errorf("fencing primary %q: %w", oldPrimary, err)
+	}
+
+	if err := dp.promote(ctx, target, state.User); err != nil {
+		return err
+	}
+	fmt.Printf("Promoted %s to primary %s after fencing %s\n", target, time.Since(start).Round(time.Millisecond), oldPrimary)
+
+	if err := dp.reassignPrimary(ctx, state, target); err != nil {
+		return err
+	}
+	// The new primary was cloned before the synchronous settings were applied.
+	if err := pgsetup.ApplySynchronousSettings(ctx, dp.runtime(), target, state.User,
+		state.SynchronousStandbyNames, state.SynchronousCommit); err != nil {
+		return fmt.Errorf("configuring synchronous replication on %q: %w", target, err)
+	}
+
+	state.FencedContainers = append(state.FencedContainers, oldPrimary)
+	if err := SaveLocalState(dp.cluster, *state); err != nil {
+		return fmt.Errorf("saving local state: %w", err)
+	}
+	return nil
+}
+
+// reassignPrimary makes the promoted standby newPrimary the primary in state
+// and repoints the standbys that streamed from the old primary at it.
+// Cascading standbys keep their upstream, including those already streaming
+// from newPrimary.
+func (dp *DockerPostgresProvider) reassignPrimary(ctx context.Context, state *LocalState, newPrimary string) error {
+	oldPrimary := state.PrimaryContainer
+	remaining := slices.DeleteFunc(slices.Clone(state.ReplicaContainers), func(r string) bool { return r == newPrimary })
+
+	var direct []string
+	for _, replica := range remaining {
+		if state.UpstreamOf(replica) == oldPrimary {
+			direct = append(direct, replica)
+		}
+	}
+	if err := dp.repointStandbys(ctx, newPrimary, state.User, direct); err != nil {
+		return err
+	}
+
+	if state.Upstreams == nil {
+		state.Upstreams = make(map[string]string, len(direct))
+	}
+	for _, replica := range direct {
+		state.Upstreams[replica] = newPrimary
+	}
+	delete(state.Upstreams, newPrimary)
+	delete(state.ReplicationSlots, newPrimary)
+
+	state.PrimaryContainer = newPrimary
+	state.ReplicaContainers = remaining
+	return nil
+}
+
+// promote turns the standby in container into a primary and waits for the
+// promotion to complete.
+func (dp *DockerPostgresProvider) promote(ctx context.Context, container, user string) error {
+	fmt.Printf("Running SQL on %s: promote standby\n", container)
+	out, err := dp.psqlQuery(ctx, container, user, "", fmt.Sprintf("SELECT pg_promote(true, %d)", promoteWaitSeconds))
+	if err != nil {
+		return fmt.Errorf("promoting %q: %w", container, err)
+	}
+	if out != "t" {
+		return fmt.Errorf("promoting %q: promotion did not complete within %ds", container, promoteWaitSeconds)
+	}
+	return nil
+}
+
+// repointStandbys makes each standby stream from newPrimary through its own
+// slot there. primary_conninfo and primary_slot_name are reloadable, so the
+// WAL receiver reconnects without a restart and follows the new timeline.
+func (dp *DockerPostgresProvider) repointStandbys(ctx context.Context, newPrimary, user string, standbys []string) error {
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return err
+	}
+	for _, standby := range standbys {
+		up := pgsetup.Upstream{
+			Host:            newPrimary,
+			Port:            5432,
+			ApplicationName: standby,
+			SlotName:        pgsetup.SlotName(standby),
+		}
+		if err := dp.createPhysicalSlot(ctx, newPrimary, user, up.SlotName); err != nil {
+			return err
+		}
+		stmts := []string{
+			fmt.Sprintf("ALTER SYSTEM SET primary_conninfo = %s", pgsetup.QuoteLiteral(pgsetup.PrimaryConninfo(up, pw))),
+			fmt.Sprintf("ALTER SYSTEM SET primary_slot_name = %s", pgsetup.QuoteLiteral(up.SlotName)),
+			"SELECT pg_reload_conf()",
+		}
+		for _, stmt := range stmts {
+			if err := dp.psqlExec(ctx, standby, user, "repoint standby to "+newPrimary, stmt); err != nil {
+				return fmt.Errorf("repointing %q to %q: %w", standby, newPrimary, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package dockerpg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/docker"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+)
+
+// Labels the Docker provider puts on node containers in addition to
+// pgsetup.LabelCluster and pgsetup.LabelRole, which it also puts on the
+// network.
+const (
+	labelNodeIndex  = "pg-telemetry-lab.node-index" // 0 for the primary, n for replica n
+	labelConfigHash = "pg-telemetry-lab.config-hash"
+)
+
+// nodeLabels returns the labels of a node container.
+func nodeLabels(cfg *config.Config, role string, index int) map[string]string {
+	return map[string]string{
+		pgsetup.LabelCluster: cfg.ClusterName(),
+		pgsetup.LabelRole:    role,
+		labelNodeIndex:       strconv.Itoa(index),
+		labelConfigHash:      cfg.Hash(),
+	}
+}
+
+// replicaIndex returns the 1-based position of a replica in the config. A
+// replica added by scale beyond replicas.count takes the number in its name.
+func replicaIndex(cfg *config.Config, name string) int {
+	if i := slices.Index(cfg.ReplicaNames(), name); i >= 0 {
+		return i + 1
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(name, cfg.Postgres.Replicas.NamePrefix))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// clusterContainers lists every container, running or not, labelled as part
+// of cluster.
+func (dp *DockerPostgresProvider) clusterContainers(ctx context.Context, cluster string) ([]docker.ContainerSummary, error) {
+	list, err := dp.client.ListContainers(ctx, true, pgsetup.LabelCluster+"="+cluster)
+	if err != nil {
+		return nil, fmt.Errorf("listing containers of cluster %q: %w", cluster, err)
+	}
+	return list, nil
+}
+
+// dataVolume returns the named volume or host path mounted at the data
+// directory of c, or "" for anonymous storage.
+func dataVolume(c docker.ContainerSummary) string {
+	for _, m := range c.Mounts {
+		if m.Destination != pgsetup.DataDir {
+			continue
+		}
+		switch m.Type {
+		case "bind":
+			return m.Source
+		case "volume":
+			// Anonymous volumes have generated 64-character names.
+			if len(m.Name) != 64 {
+				return m.Name
+			}
+		}
+	}
+	return ""
+}
+
+// discoverState finds the cluster through its container labels and merges
+// it with the state file, which acts as a cache of what labels cannot tell
+// (slots, upstreams, settings). It also returns the discovered containers.
+// Without either, it returns ErrNoLocalState.
+func (dp *DockerPostgresProvider) discoverState(ctx context.Context) (*LocalState, []docker.ContainerSummary, error) {
+	state, err := LoadLocalState(dp.cluster)
+	if err != nil && !errors.Is(err, ErrNoLocalState) {
+		return nil, nil, fmt.Errorf("loading local state: %w", err)
+	}
+	found, err := dp.clusterContainers(ctx, dp.cluster)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case state != nil:
+		state.adopt(found)
+	case len(found) > 0:
+		fmt.Printf("No state file at %s, found %d labelled containers\n", StatePath(dp.cluster), len(found))
+		state = stateFromContainers(found)
+	default:
+		return nil, nil, ErrNoLocalState
+	}
+	return state, found, nil
+}
+
+// stateFromContainers rebuilds a best-effort state from labelled containers
+// when the state file is missing. Slots and upstreams cannot be recovered,
+// so destroy leaves slot cleanup to the removal of the primary.
+func stateFromContainers(found []docker.ContainerSummary) *LocalState {
+	state := &LocalState{}
+	var primaries []docker.ContainerSummary
+	for _, c := range found {
+		switch c.Labels[pgsetup.LabelRole] {
+		case pgsetup.RolePrimary:
+			primaries = append(primaries, c)
+			continue
+		case pgsetup.RoleSubscriber:
+			state.ReplicationMode = config.ReplicationLogical
+		}
+		state.ReplicaContainers = append(state.ReplicaContainers, c.Name())
+		state.setVolume(c.Name(), dataVolume(c))
+	}
+	// A fenced former primary is stopped; prefer the running one.
+	slices.SortStableFunc(primaries, func(a, b docker.ContainerSummary) int {
+		if (a.State == "running") == (b.State == "running") {
+			return 0
+		}
+		if a.State == "running" {
+			return -1
+		}
+		return 1
+	})
+	for i, c := range primaries {
+		if i == 0 {
+			state.PrimaryContainer = c.Name()
+		} else {
+			state.FencedContainers = append(state.FencedContainers, c.Name())
+		}
+		state.setVolume(c.Name(), dataVolume(c))
+	}
+	return state
+}
+
+// adopt adds labelled containers the state does not know about, e.g. ones
+// created by a run whose state was lost, so destroy removes them too.
+func (s *LocalState) adopt(found []docker.ContainerSummary) {
+	known := slices.Concat([]string{s.PrimaryContainer}, s.ReplicaContainers, s.FencedContainers)
+	for _, c := range found {
+		name := c.Name()
+		if slices.Contains(known, name) {
+			continue
+		}
+		if c.Labels[pgsetup.LabelRole] == pgsetup.RolePrimary {
+			s.FencedContainers = append(s.FencedContainers, name)
+		} else {
+			s.ReplicaContainers = append(s.ReplicaContainers, name)
+		}
+		if _, ok := s.Volumes[name]; !ok {
+			s.setVolume(name, dataVolume(c))
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package dockerpg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+)
+
+// ReplicationLag returns lag figures for every standby of the cluster.
+func (dp *DockerPostgresProvider) ReplicationLag() ([]provider.ReplicaLag, error) {
+	ctx := context.Background()
+	state, err := LoadLocalState(dp.cluster)
+	if err != nil {
+		return nil, fmt.Errorf("loading local state: %w", err)
+	}
+	if state.ReplicationMode == config.ReplicationLogical {
+		return nil, fmt.Errorf("lag reporting is only supported in %q replication mode", config.ReplicationPhysical)
+	}
+
+	primaryLSN, err := dp.psqlQuery(ctx, state.PrimaryContainer, state.User, "", "SELECT pg_current_wal_lsn()")
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT coalesce(pg_last_wal_receive_lsn()::text, ''),
+       coalesce(pg_last_wal_replay_lsn()::text, ''),
+       coalesce(pg_wal_lsn_diff(%s, pg_last_wal_receive_lsn())::bigint, 0),
+       coalesce(pg_wal_lsn_diff(pg_last_wal_receive_lsn(), pg_last_wal_replay_lsn())::bigint, 0),
+       coalesce((extract(epoch FROM now() - pg_last_xact_replay_timestamp()) * 1000)::bigint, -1)`,
+		pgsetup.QuoteLiteral(primaryLSN))
+
+	lags := make([]provider.ReplicaLag, 0, len(state.ReplicaContainers))
+	for _, replica := range state.ReplicaContainers {
+		out, err := dp.psqlQuery(ctx, replica, state.User, "", query)
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Split(out, "|")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("unexpected lag row %q from %q", out, replica)
+		}
+
+		lag := provider.ReplicaLag{
+			Name:        replica,
+			Upstream:    state.UpstreamOf(replica),
+			ApplyDelay:  state.ApplyDelays[replica],
+			ReceivedLSN: fields[0],
+			ReplayedLSN: fields[1],
+		}
+		if lag.ReceiveLagBytes, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+			return nil, fmt.Errorf("parsing receive lag of %q: %w", replica, err)
+		}
+		if lag.ReplayLagBytes, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+			return nil, fmt.Errorf("parsing replay lag of %q: %w", replica, err)
+		}
+		ageMillis, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing replay age of %q: %w", replica, err)
+		}
+		lag.ReplayAge = time.Duration(ageMillis) * time.Millisecond
+		lags = append(lags, lag)
+	}
+	return lags, nil
+}
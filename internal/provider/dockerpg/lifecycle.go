@@ -0,0 +1,101 @@
+package dockerpg
+
+import (
+	"context"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+)
+
+// Status reports the cluster's containers as provider.NodeStatus.
+// Standbys and subscribers are both replicas there; ContainerStatus keeps
+// the distinction and the Docker details.
+func (dp *DockerPostgresProvider) Status(ctx context.Context) (*provider.ClusterStatus, error) {
+	detailed, err := dp.ContainerStatus()
+	if err != nil {
+		return nil, err
+	}
+	status := &provider.ClusterStatus{Cluster: detailed.Cluster}
+	if state, err := LoadLocalState(dp.cluster); err == nil && state.Failed() {
+		status.Error = state.Error
+	}
+	for _, n := range detailed.Nodes {
+		node := provider.NodeStatus{Name: n.Name, Role: provider.RoleUnknown, State: provider.StateStopped}
+		switch n.Role {
+		case statusPrimary:
+			node.Role = provider.RolePrimary
+		case statusStandby, statusSubscriber:
+			node.Role = provider.RoleReplica
+		case statusFenced:
+			node.Role = provider.RoleFenced
+		}
+		switch n.State {
+		case "running":
+			node.State = provider.StateRunning
+		case "missing":
+			node.State = provider.StateMissing
+		}
+		status.Nodes = append(status.Nodes, node)
+	}
+	return status, nil
+}
+
+// Endpoints returns the primary and replica containers from the local
+// state. Inside the Docker network every server listens on 5432 under its
+// container name; from outside, on the host port it publishes, if any.
+func (dp *DockerPostgresProvider) Endpoints(ctx context.Context) ([]provider.Endpoint, error) {
+	state, err := LoadLocalState(dp.cluster)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.requireReady(); err != nil {
+		return nil, err
+	}
+	found, err := dp.clusterContainers(ctx, dp.cluster)
+	if err != nil {
+		return nil, err
+	}
+	published := make(map[string]int, len(found))
+	for _, c := range found {
+		for _, p := range c.Ports {
+			if p.PrivatePort == 5432 && p.PublicPort != 0 {
+				published[c.Name()] = p.PublicPort
+			}
+		}
+	}
+
+	endpoints := make([]provider.Endpoint, 0, len(state.ReplicaContainers)+1)
+	add := func(name, role string) {
+		e := provider.Endpoint{Node: name, Role: role, Host: name, Port: 5432}
+		if port, ok := published[name]; ok {
+			e.ExternalHost, e.ExternalPort = dp.client.PublishHost(), port
+		}
+		endpoints = append(endpoints, e)
+	}
+	add(state.PrimaryContainer, provider.RolePrimary)
+	for _, name := range state.ReplicaContainers {
+		add(name, provider.RoleReplica)
+	}
+	return endpoints, nil
+}
+
+// Stop stops the replica containers, downstream ones first, then the
+// primary. Containers and volumes are kept; fenced containers are already
+// stopped.
+func (dp *DockerPostgresProvider) Stop(ctx context.Context) error {
+	state, err := LoadLocalState(dp.cluster)
+	if err != nil {
+		return err
+	}
+	return dp.stopNodes(ctx, state, nodeOrder(state, nil, true), shutdownTimeoutSeconds)
+}
+
+// Start starts the primary, then the replicas, upstreams first, waiting
+// until each accepts connections. Fenced containers stay stopped.
+func (dp *DockerPostgresProvider) Start(ctx context.Context) error {
+	state, err := LoadLocalState(dp.cluster)
+	if err != nil {
+		return err
+	}
+	_, err = dp.startNodes(ctx, state, nodeOrder(state, nil, false))
+	return err
+}
@@ -0,0 +1,183 @@
+package dockerpg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
+)
+
+// schemaDumpPath is where subscribers stage the primary's schema before
+// applying it.
+const schemaDumpPath = "/tmp/telemetry-schema.sql"
+
+// runSubscriber starts an independent Postgres server that receives changes
+// from the primary through a logical replication subscription.
+func (dp *DockerPostgresProvider) runSubscriber(ctx context.Context, cfg *config.Config, node config.ReplicaNode, plan *reconcilePlan) error {
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return err
+	}
+
+	name := node.Name
+	hostPort := node.Port
+	limits, err := cfg.ReplicaResources(node).Limits()
+	if err != nil {
+		return err
+	}
+
+	spec := containerSpec{
+		Name:     name,
+		Image:    cfg.Postgres.Image,
+		Network:  cfg.Postgres.Network,
+		HostPort: hostPort,
+		Env: []string{
+			"POSTGRES_USER=" + cfg.Postgres.Primary.User,
+			"POSTGRES_PASSWORD=" + pw,
+			"POSTGRES_DB=" + cfg.Postgres.Primary.Database,
+			// pg_dump reads the primary's schema with the same credentials.
+			"PGPASSWORD=" + pw,
+			"PGDATA=" + pgsetup.DataDir,
+		},
+		Cmd:    append([]string{"postgres"}, pgsetup.ServerArgs(config.ReplicationLogical, cfg.ReplicaParameters(node))...),
+		Volume: node.Volume,
+		Limits: limits,
+		Labels: nodeLabels(cfg, pgsetup.RoleSubscriber, replicaIndex(cfg, name)),
+	}
+	if err := dp.ensureContainer(ctx, spec, plan, recreateReason(plan, node)); err != nil {
+		return fmt.Errorf("running subscriber container %q: %w", name, err)
+	}
+	if err := pgsetup.WaitForPostgres(ctx, dp.runtime(), name, cfg.ReadinessTimeout()); err != nil {
+		return err
+	}
+	return pgsetup.WriteHBARules(ctx, dp.runtime(), name, cfg.Postgres.Primary.User, cfg.ReplicaHBA(node))
+}
+
+// setupLogicalReplication creates the publication on the primary, copies the
+// schema to every subscriber and subscribes it. It returns the subscription
+// name per subscriber container.
+func (dp *DockerPostgresProvider) setupLogicalReplication(ctx context.Context, cfg *config.Config, subscribers []string) (map[string]string, error) {
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return nil, err
+	}
+	primary := cfg.Postgres.Primary.HostName
+	user := cfg.Postgres.Primary.User
+	database := cfg.Postgres.Primary.Database
+	publication := cfg.PublicationName()
+
+	exists, err := dp.psqlQuery(ctx, primary, user, database,
+		fmt.Sprintf("SELECT count(*) FROM pg_publication WHERE pubname = %s", pgsetup.QuoteLiteral(publication)))
+	if err != nil {
+		return nil, err
+	}
+	if exists == "0" {
+		stmt := fmt.Sprintf("CREATE PUBLICATION %s FOR ALL TABLES", pgsetup.QuoteIdent(publication))
+		if err := dp.psqlExecIn(ctx, primary, user, database, "create publication "+publication, stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	conninfo := fmt.Sprintf("host=%s port=5432 user=%s dbname=%s password=%s", primary, user, database, pw)
+
+	subs := make(map[string]string, len(subscribers))
+	for _, sub := range subscribers {
+		name := subscriptionName(publication, sub)
+		exists, err := dp.psqlQuery(ctx, sub, user, database,
+			fmt.Sprintf("SELECT count(*) FROM pg_subscription WHERE subname = %s", pgsetup.QuoteLiteral(name)))
+		if err != nil {
+			return nil, err
+		}
+		// A subscriber that is already subscribed kept its schema from an
+		// earlier run; copying it again would fail on existing tables.
+		if exists == "0" {
+			if err := dp.copySchema(ctx, primary, sub, user, database, false); err != nil {
+				return nil, err
+			}
+			stmt := fmt.Sprintf("CREATE SUBSCRIPTION %s CONNECTION %s PUBLICATION %s",
+				pgsetup.QuoteIdent(name), pgsetup.QuoteLiteral(conninfo), pgsetup.QuoteIdent(publication))
+			if err := dp.psqlExecIn(ctx, sub, user, database, "create subscription "+name, stmt); err != nil {
+				return nil, err
+			}
+		}
+		subs[sub] = name
+	}
+	return subs, nil
+}
+
+// SyncSubscribers brings the subscribers of a logical-mode cluster in line
+// with the primary's current schema and refreshes their subscriptions so
+// newly created tables (e.g. after `pgbench -i`) are replicated as well.
+func (dp *DockerPostgresProvider) SyncSubscribers() error {
+	ctx := context.Background()
+	state, err := LoadLocalState(dp.cluster)
+	if err != nil {
+		return fmt.Errorf("loading local state: %w", err)
+	}
+	if err := state.requireReady(); err != nil {
+		return err
+	}
+	if state.ReplicationMode != config.ReplicationLogical {
+		return nil
+	}
+
+	subscribers := make([]string, 0, len(state.Subscriptions))
+	for sub := range state.Subscriptions {
+		subscribers = append(subscribers, sub)
+	}
+	sort.Strings(subscribers)
+
+	for _, sub := range subscribers {
+		// Tables recreated on the primary are dropped and recreated here, which
+		// also removes them from the subscription until the refresh below.
+		if err := dp.copySchema(ctx, state.PrimaryContainer, sub, state.User, state.Database, true); err != nil {
+			return err
+		}
+		name := state.Subscriptions[sub]
+		stmt := fmt.Sprintf("ALTER SUBSCRIPTION %s REFRESH PUBLICATION", pgsetup.QuoteIdent(name))
+		if err := dp.psqlExecIn(ctx, sub, state.User, state.Database, "refresh subscription "+name, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copySchema dumps the primary's schema from inside the subscriber container
+// and applies it to the subscriber. With clean set, existing objects are
+// dropped first.
+func (dp *DockerPostgresProvider) copySchema(ctx context.Context, primary, subscriber, user, database string, clean bool) error {
+	dump := []string{
+		"pg_dump", "-h", primary, "-p", "5432", "-U", user, "-d", database,
+		"--schema-only", "--no-owner", "--no-publications", "--no-subscriptions",
+		"-f", schemaDumpPath,
+	}
+	if clean {
+		dump = append(dump, "--clean", "--if-exists")
+	}
+	restore := []string{
+		"psql", "-X", "-q", "-v", "ON_ERROR_STOP=1", "-U", user, "-d", database, "-f", schemaDumpPath,
+	}
+
+	script := strings.Join([]string{
+		"set -e",
+		pgsetup.ShellJoin(dump),
+		pgsetup.ShellJoin(restore),
+		"rm -f " + schemaDumpPath,
+	}, "\n")
+
+	fmt.Printf("Copying schema from %s to %s\n", primary, subscriber)
+	if _, err := dp.dockerExec(ctx, subscriber, "sh", "-c", script); err != nil {
+		return fmt.Errorf("copying schema to %q: %w", subscriber, err)
+	}
+	return nil
+}
+
+// subscriptionName derives a per-subscriber subscription name. It is also the
+// name of the replication slot the subscription creates on the primary.
+func subscriptionName(publication, subscriber string) string {
+	return publication + "_" + pgsetup.SafeName(subscriber)
+}
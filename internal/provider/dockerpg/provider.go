@@ -1,170 +1,408 @@
 package dockerpg
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
-	"os/exec"
+	"slices"
 	"strings"
 
 	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/docker"
 	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
 	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
 )
 
-var _ provider.PostgresProvider = (*DockerPostgresProvider)(nil)
+var (
+	_ provider.PostgresProvider   = (*DockerPostgresProvider)(nil)
+	_ provider.OptionsProvisioner = (*DockerPostgresProvider)(nil)
+	_ provider.SlotLister         = (*DockerPostgresProvider)(nil)
+	_ provider.LagReporter        = (*DockerPostgresProvider)(nil)
+	_ provider.Failoverer         = (*DockerPostgresProvider)(nil)
+	_ provider.Switchoverer       = (*DockerPostgresProvider)(nil)
+	_ provider.Scaler             = (*DockerPostgresProvider)(nil)
+	_ provider.NodeRestarter      = (*DockerPostgresProvider)(nil)
+	_ provider.ClusterLister      = (*DockerPostgresProvider)(nil)
+	_ provider.SubscriberSyncer   = (*DockerPostgresProvider)(nil)
+)
 
-type DockerPostgresProvider struct{}
+type DockerPostgresProvider struct {
+	client *docker.Client
+	// cluster selects the state file and the labels used for discovery.
+	cluster string
+}
 
-func NewDockerPostgresProvider() *DockerPostgresProvider {
-	return &DockerPostgresProvider{}
+// NewDockerPostgresProvider returns a provider managing the named cluster
+// through client. An empty cluster means config.DefaultCluster.
+func NewDockerPostgresProvider(client *docker.Client, cluster string) *DockerPostgresProvider {
+	if cluster == "" {
+		cluster = config.DefaultCluster
+	}
+	return &DockerPostgresProvider{client: client, cluster: cluster}
 }
 
 // ProvisionPostgres starts the primary and replica Postgres containers using Docker.
+// In physical mode replicas are seeded from their upstream (the primary or,
+// for cascading standbys, another replica) with pg_basebackup and stream WAL
+// from it; in logical mode they are independent servers subscribed to a
+// publication on the primary.
+//
+// Provisioning is a reconcile: containers that already match the config are
+// kept, stopped ones are started, drifted ones are recreated and replicas no
+// longer in the config are removed. Running it twice is a no-op.
+//
+// If provisioning fails part way, the resources created so far are saved in
+// the local state marked as failed, so destroy can remove them and a later
+// provision can pick up where this one stopped.
 func (dp *DockerPostgresProvider) ProvisionPostgres(cfg *config.Config) error {
-	if err := ensureNetwork(cfg.Postgres.Network); err != nil {
+	return dp.ProvisionPostgresWithOptions(cfg, provider.ProvisionOptions{})
+}
+
+// ProvisionPostgresWithOptions is ProvisionPostgres, refusing to recreate a
+// drifted container that keeps its data in anonymous storage unless
+// Recreate is set.
+func (dp *DockerPostgresProvider) ProvisionPostgresWithOptions(cfg *config.Config, opts provider.ProvisionOptions) error {
+	ctx := context.Background()
+	if cfg.ClusterName() != dp.cluster {
+		return fmt.Errorf("config is for cluster %q but the provider manages %q", cfg.ClusterName(), dp.cluster)
+	}
+
+	// Upstreams must be running before the replicas cloned from them.
+	nodes, err := cfg.OrderedReplicaNodes()
+	if err != nil {
+		return fmt.Errorf("ordering replicas: %w", err)
+	}
+
+	prev, err := LoadLocalState(dp.cluster)
+	if err != nil && !errors.Is(err, ErrNoLocalState) {
+		return fmt.Errorf("loading local state: %w", err)
+	}
+	if prev != nil && prev.PrimaryContainer != cfg.Postgres.Primary.HostName {
+		return fmt.Errorf("the primary is now %q after a failover or switchover, not the configured %q; "+
+			"run destroy local before provisioning from config again", prev.PrimaryContainer, cfg.Postgres.Primary.HostName)
+	}
+
+	state := LocalState{
+		Cluster:          dp.cluster,
+		PrimaryContainer: cfg.Postgres.Primary.HostName,
+		Image:            cfg.Postgres.Image,
+		Network:          cfg.Postgres.Network,
+		User:             cfg.Postgres.Primary.User,
+		Database:         cfg.Postgres.Primary.Database,
+		ReplicationMode:  cfg.ReplicationMode(),
+		ReadinessTimeout: cfg.ReadinessTimeout().String(),
+	}
+	state.setVolume(state.PrimaryContainer, cfg.Postgres.Primary.Volume)
+	plan := newReconcilePlan()
+	plan.recreate = opts.Recreate
+	if err := dp.provision(ctx, cfg, nodes, prev, plan, &state); err != nil {
+		state.Status = StatusFailed
+		state.Error = err.Error()
+		if prev != nil {
+			state.keepUntracked(prev)
+		}
+		if saveErr := SaveLocalState(dp.cluster, state); saveErr != nil {
+			return fmt.Errorf("%w (saving partial local state: %v)", err, saveErr)
+		}
+		return fmt.Errorf("%w; run destroy local to remove the partially provisioned cluster", err)
+	}
+
+	state.Status = StatusReady
+	if err := SaveLocalState(dp.cluster, state); err != nil {
+		return fmt.Errorf("saving local state: %w", err)
+	}
+	plan.print()
+	return nil
+}
+
+// provision brings the cluster in line with cfg, recording every container
+// in state as soon as it may exist.
+func (dp *DockerPostgresProvider) provision(ctx context.Context, cfg *config.Config, nodes []config.ReplicaNode,
+	prev *LocalState, plan *reconcilePlan, state *LocalState) error {
+	mode := cfg.ReplicationMode()
+
+	created, err := dp.ensureNetwork(ctx, cfg.Postgres.Network)
+	if err != nil {
 		return fmt.Errorf("ensuring docker network %q: %w", cfg.Postgres.Network, err)
 	}
-	if err := dp.runPrimary(cfg); err != nil {
+	networkAction := actionUnchanged
+	if created {
+		networkAction = actionCreated
+	}
+	plan.record("network "+cfg.Postgres.Network, networkAction, "")
+
+	if err := dp.runPrimary(ctx, cfg, plan); err != nil {
 		return fmt.Errorf("running primary Postgres container: %w", err)
 	}
-	replicas := make([]string, 0, cfg.Postgres.Replicas.Count)
-	for i := 0; i < cfg.Postgres.Replicas.Count; i++ {
-		if err := dp.runReplica(cfg, i); err != nil {
-			return fmt.Errorf("running replica %d Postgres container: %w", i+1, err)
+	if len(nodes) > 0 && mode == config.ReplicationPhysical {
+		if err := pgsetup.PreparePrimaryForReplication(ctx, dp.runtime(), cfg); err != nil {
+			return fmt.Errorf("preparing primary for replication: %w", err)
 		}
-		replicas = append(replicas, replicaName(cfg, i))
+		state.ReplicationSlots = make(map[string]string, len(nodes))
+		state.Upstreams = make(map[string]string, len(nodes))
+	} else if err := pgsetup.WriteHBARules(ctx, dp.runtime(), state.PrimaryContainer, state.User, cfg.PrimaryHBA()); err != nil {
+		return fmt.Errorf("configuring pg_hba.conf on the primary: %w", err)
 	}
-	state := LocalState{
-		PrimaryContainer:  cfg.Postgres.Primary.HostName,
-		ReplicaContainers: replicas,
-		Image:             cfg.Postgres.Image,
+	for _, node := range nodes {
+		run := dp.runReplica
+		if mode == config.ReplicationLogical {
+			run = dp.runSubscriber
+		}
+		// Recorded up front: the slot and container may exist even if
+		// the replica never becomes ready.
+		state.ReplicaContainers = append(state.ReplicaContainers, node.Name)
+		state.setVolume(node.Name, node.Volume)
+		if mode == config.ReplicationPhysical {
+			state.ReplicationSlots[node.Name] = pgsetup.SlotName(node.Name)
+			state.Upstreams[node.Name] = node.Upstream
+			if node.ApplyDelay != "" {
+				if state.ApplyDelays == nil {
+					state.ApplyDelays = make(map[string]string)
+				}
+				state.ApplyDelays[node.Name] = node.ApplyDelay
+			}
+		}
+		if err := run(ctx, cfg, node, plan); err != nil {
+			return fmt.Errorf("running replica %q Postgres container: %w", node.Name, err)
+		}
 	}
-	if err := SaveLocalState(state); err != nil {
-		return fmt.Errorf("saving local state: %w", err)
+	if prev != nil {
+		if err := dp.removeOrphans(ctx, prev, cfg, plan); err != nil {
+			return err
+		}
+	}
+
+	if mode == config.ReplicationPhysical && len(nodes) > 0 {
+		state.SynchronousStandbyNames = cfg.SynchronousStandbyNames()
+		state.SynchronousCommit = cfg.Postgres.Replication.SynchronousCommit
+		if err := pgsetup.ApplySynchronousSettings(ctx, dp.runtime(), state.PrimaryContainer, state.User,
+			state.SynchronousStandbyNames, state.SynchronousCommit); err != nil {
+			return fmt.Errorf("configuring synchronous replication: %w", err)
+		}
+	}
+	if mode == config.ReplicationLogical && len(nodes) > 0 {
+		state.Publication = cfg.PublicationName()
+		subs, err := dp.setupLogicalReplication(ctx, cfg, state.ReplicaContainers)
+		if err != nil {
+			return fmt.Errorf("setting up logical replication: %w", err)
+		}
+		state.Subscriptions = subs
+	}
+
+	// Only report success once the whole cluster is usable, so a benchmark
+	// started right after provisioning does not race a starting server.
+	if err := dp.waitForCluster(ctx, state, cfg.ReadinessTimeout()); err != nil {
+		return fmt.Errorf("waiting for cluster readiness: %w", err)
+	}
+	return nil
+}
+
+// removeOrphans removes replicas and fenced former primaries recorded in the
+// previous state that are no longer part of the config, dropping their
+// slots on upstreams that remain. A removed replica's data volume goes too:
+// without its slot it could not resume streaming if it were added back.
+func (dp *DockerPostgresProvider) removeOrphans(ctx context.Context, prev *LocalState, cfg *config.Config, plan *reconcilePlan) error {
+	desired := cfg.ReplicaNames()
+	orphans := slices.Concat(prev.ReplicaContainers, prev.FencedContainers)
+	for _, name := range orphans {
+		if slices.Contains(desired, name) || name == cfg.Postgres.Primary.HostName {
+			continue
+		}
+		if err := dp.removeContainer(ctx, name); err != nil {
+			return fmt.Errorf("removing container %q: %w", name, err)
+		}
+		// The slot lives on the upstream, which may itself be going away.
+		upstream := prev.UpstreamOf(name)
+		kept := upstream == cfg.Postgres.Primary.HostName || slices.Contains(desired, upstream)
+		if slot, ok := prev.ReplicationSlots[name]; ok && kept {
+			if err := dp.dropSlot(ctx, upstream, prev.User, slot); err != nil {
+				return fmt.Errorf("dropping replication slot %q: %w", slot, err)
+			}
+		}
+		if volume, ok := prev.Volumes[name]; ok && slices.Contains(prev.ReplicaContainers, name) {
+			if err := dp.removeData(ctx, volume); err != nil {
+				return fmt.Errorf("removing data of %q: %w", name, err)
+			}
+		}
+		plan.record(name, actionRemoved, "no longer in config")
 	}
 	return nil
 }
 
-// DestroyPostgres stops and removes the primary and replica Postgres containers
+// DestroyPostgres stops and removes the primary and replica Postgres
+// containers, keeping any persistent data volumes.
 func (dp *DockerPostgresProvider) DestroyPostgres() error {
-	state, err := LoadLocalState()
+	return dp.DestroyPostgresWithOptions(provider.DestroyOptions{})
+}
+
+// DestroyPostgresWithOptions stops and removes the primary and replica
+// Postgres containers. Replicas are removed first so their replication slots
+// are inactive and can be dropped on the primary before it is removed.
+//
+// Without Purge, nodes with a volume keep their data and a later provision
+// starts them from it; slots of standbys that keep data stay on the primary
+// so they can resume streaming. Purge removes the named data volumes as
+// well; host paths are never deleted. The cluster's network goes last, once
+// no container is attached to it.
+func (dp *DockerPostgresProvider) DestroyPostgresWithOptions(opts provider.DestroyOptions) error {
+	ctx := context.Background()
+	state, _, err := dp.discoverState(ctx)
 	if err != nil {
-		return fmt.Errorf("loading local state: %w", err)
+		return err
 	}
 	var errs []string
-	if err := runCommand("docker", "rm", "-f", state.PrimaryContainer); err != nil {
-		errs = append(errs, fmt.Sprintf("removing primary container %q: %v", state.PrimaryContainer, err))
-	}
 	for _, replica := range state.ReplicaContainers {
-		if err := runCommand("docker", "rm", "-f", replica); err != nil {
+		if err := dp.removeContainer(ctx, replica); err != nil {
 			errs = append(errs, fmt.Sprintf("removing replica container %q: %v", replica, err))
 		}
 	}
+	// A primary that never came up (failed provisioning) has no slots to drop.
+	primaryRunning := false
+	if info, err := dp.client.InspectContainer(ctx, state.PrimaryContainer); err == nil {
+		primaryRunning = info.State.Running
+	}
+	for _, replica := range state.ReplicaContainers {
+		// Slots of cascading standbys live on replicas removed above.
+		slot, ok := state.ReplicationSlots[replica]
+		if !ok || !primaryRunning || state.UpstreamOf(replica) != state.PrimaryContainer {
+			continue
+		}
+		if !opts.Purge && state.Volumes[replica] != "" && state.Volumes[state.PrimaryContainer] != "" {
+			continue
+		}
+		if err := dp.dropSlot(ctx, state.PrimaryContainer, state.User, slot); err != nil {
+			errs = append(errs, fmt.Sprintf("dropping replication slot %q: %v", slot, err))
+		}
+	}
+	if state.PrimaryContainer != "" {
+		if err := dp.removeContainer(ctx, state.PrimaryContainer); err != nil {
+			errs = append(errs, fmt.Sprintf("removing primary container %q: %v", state.PrimaryContainer, err))
+		}
+	}
+	for _, fenced := range state.FencedContainers {
+		if err := dp.removeContainer(ctx, fenced); err != nil {
+			errs = append(errs, fmt.Sprintf("removing fenced container %q: %v", fenced, err))
+		}
+	}
+	if opts.Purge {
+		for _, name := range slices.Sorted(maps.Keys(state.Volumes)) {
+			volume, ok := state.Volumes[name]
+			if !ok {
+				continue
+			}
+			if err := dp.removeData(ctx, volume); err != nil {
+				errs = append(errs, fmt.Sprintf("removing data of %q: %v", name, err))
+			}
+		}
+	} else {
+		for _, name := range slices.Sorted(maps.Keys(state.Volumes)) {
+			fmt.Printf("Keeping data of %s in %s\n", name, state.Volumes[name])
+		}
+	}
+	if err := dp.removeNetworks(ctx); err != nil {
+		errs = append(errs, err.Error())
+	}
 	if len(errs) > 0 {
 		return fmt.Errorf("errors occurred while destroying containers: %s", strings.Join(errs, "; "))
 	}
-	_ = os.Remove(LocalStatePath)
+	_ = os.Remove(StatePath(dp.cluster))
 
 	return nil
 }
 
-func (dp *DockerPostgresProvider) runPrimary(cfg *config.Config) error {
+func (dp *DockerPostgresProvider) runPrimary(ctx context.Context, cfg *config.Config, plan *reconcilePlan) error {
 	pw, err := util.GetRequiredEnv("PG_PASSWORD")
 	if err != nil {
 		return err
 	}
-
-	args := []string{
-		"run", "-d",
-		"--name", cfg.Postgres.Primary.HostName,
-		"--network", cfg.Postgres.Network,
-		"-e", "POSTGRES_USER=" + cfg.Postgres.Primary.User,
-		"-e", "POSTGRES_PASSWORD=" + pw,
-		"-e", "POSTGRES_DB=" + cfg.Postgres.Primary.Database,
-		"-p", fmt.Sprintf("%d:5432", cfg.Postgres.Primary.Port),
-		cfg.Postgres.Image,
+	limits, err := cfg.Postgres.Primary.Resources.Limits()
+	if err != nil {
+		return err
 	}
 
-	// Mask password when printing command
-	printArgs := util.MaskArgs(args)
-
-	fmt.Printf("Running: docker %s\n", strings.Join(printArgs, " "))
-
-	cmd := exec.Command("docker", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	spec := containerSpec{
+		Name:     cfg.Postgres.Primary.HostName,
+		Image:    cfg.Postgres.Image,
+		Network:  cfg.Postgres.Network,
+		HostPort: cfg.Postgres.Primary.Port,
+		Env: []string{
+			"POSTGRES_USER=" + cfg.Postgres.Primary.User,
+			"POSTGRES_PASSWORD=" + pw,
+			"POSTGRES_DB=" + cfg.Postgres.Primary.Database,
+			"PGDATA=" + pgsetup.DataDir,
+		},
+		// Passed to the image entrypoint, which hands them to the server.
+		Cmd:    append([]string{"postgres"}, pgsetup.ServerArgs(cfg.ReplicationMode(), cfg.PrimaryParameters())...),
+		Volume: cfg.Postgres.Primary.Volume,
+		Limits: limits,
+		Labels: nodeLabels(cfg, pgsetup.RolePrimary, 0),
+	}
+	if err := dp.ensureContainer(ctx, spec, plan, ""); err != nil {
+		return err
+	}
+	return pgsetup.WaitForPostgres(ctx, dp.runtime(), spec.Name, cfg.ReadinessTimeout())
 }
 
-func (dp *DockerPostgresProvider) runReplica(cfg *config.Config, index int) error {
-	// NOTE: At the moment this starts an additional standalone Postgres
-	// container with the same image and credentials as the primary. It is
-	// intended to become a replication replica in a follow-up change.
+// runReplica starts a streaming physical standby of node's upstream. The
+// container entrypoint clones the upstream with pg_basebackup on first start,
+// writes standby.signal and primary_conninfo, and then hands over to the
+// regular postgres entrypoint.
+func (dp *DockerPostgresProvider) runReplica(ctx context.Context, cfg *config.Config, node config.ReplicaNode, plan *reconcilePlan) error {
 	pw, err := util.GetRequiredEnv("PG_PASSWORD")
 	if err != nil {
 		return err
 	}
 
-	// Derive replica name and host port from config and index.
-	name := replicaName(cfg, index)
-	hostPort := cfg.Postgres.Replicas.BasePort + index
+	name := node.Name
+	hostPort := node.Port
 
-	args := []string{
-		"run", "-d",
-		"--name", name,
-		"--network", cfg.Postgres.Network, 
-		"-e", "POSTGRES_USER=" + cfg.Postgres.Primary.User,
-		"-e", "POSTGRES_PASSWORD=" + pw, 
-		"-e", "POSTGRES_DB=" + cfg.Postgres.Primary.Database,
-		"-p", fmt.Sprintf("%d:5432", hostPort),
-		cfg.Postgres.Image,
+	delay, err := node.ApplyDelayDuration()
+	if err != nil {
+		return err
 	}
-
-// TODO: configure this container as a real replica of the primary:
-	//   - enable wal_level and replication settings on the primary
-	//   - use pg_basebackup / primary_conninfo to clone data from primary
-	//   - create and use replication slots
-	//   - switch from "standalone" to streaming/logical replica
-
-	if err := runCommand("docker", args...); err != nil {
-		return fmt.Errorf("running replica container %q: %w", name, err)
+	limits, err := cfg.ReplicaResources(node).Limits()
+	if err != nil {
+		return err
 	}
-	return nil
-}
-
-func replicaName(cfg *config.Config, replicaIndex int) string {
-	// index is 0-based; names are 1-based (pg-replica-1, pg-replica-2)
-	return fmt.Sprintf("%s%d", cfg.Postgres.Replicas.NamePrefix, replicaIndex+1)
-}
-
-func runCommand(name string, args ...string) error {
-	printArgs := util.MaskArgs(args)
-	fmt.Printf("Running: %s %s\n", name, strings.Join(printArgs, " "))
-
-	cmd := exec.Command(name, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	return cmd.Run()
-}
-func ensureNetwork(network string) error {
-	if network == "" {
-		return fmt.Errorf("postgres.network must be set in config")
+	upstream := pgsetup.Upstream{
+		Host:            node.Upstream,
+		Port:            5432,
+		ApplicationName: name,
+		SlotName:        pgsetup.SlotName(name),
+		ApplyDelay:      delay,
 	}
 
-	// Check if network already exists.
-	checkCmd := exec.Command("docker", "network", "inspect", network)
-	if err := checkCmd.Run(); err == nil {
-		// Network already exists.
-		return nil
+	// The slot must exist before pg_basebackup -S uses it.
+	if err := dp.createPhysicalSlot(ctx, upstream.Host, cfg.Postgres.Primary.User, upstream.SlotName); err != nil {
+		return err
 	}
 
-	// Create the network.
-	fmt.Printf("Running: docker network create %s\n", network)
-	createCmd := exec.Command("docker", "network", "create", network)
-	createCmd.Stdout = os.Stdout
-	createCmd.Stderr = os.Stderr
-	return createCmd.Run()
+	spec := containerSpec{
+		Name:     name,
+		Image:    cfg.Postgres.Image,
+		Network:  cfg.Postgres.Network,
+		HostPort: hostPort,
+		Env: []string{
+			// pg_basebackup and primary_conninfo authenticate as the replication role.
+			"PGPASSWORD=" + pw,
+			"PGDATA=" + pgsetup.DataDir,
+		},
+		Entrypoint: []string{"sh", "-c", pgsetup.StandbyEntrypointScript(upstream, pgsetup.ServerArgs(cfg.ReplicationMode(), cfg.ReplicaParameters(node)))},
+		Volume:     node.Volume,
+		Limits:     limits,
+		Labels:     nodeLabels(cfg, pgsetup.RoleReplica, replicaIndex(cfg, name)),
+	}
+	if err := dp.ensureContainer(ctx, spec, plan, recreateReason(plan, node)); err != nil {
+		return fmt.Errorf("running replica container %q: %w", name, err)
+	}
+	if err := pgsetup.WaitForPostgres(ctx, dp.runtime(), name, cfg.ReadinessTimeout()); err != nil {
+		return err
+	}
+	// The clone carries the primary's pg_hba.conf; replace its managed block
+	// with the rules for this node.
+	rules := append([]string{pgsetup.ReplicationHBARule()}, cfg.ReplicaHBA(node)...)
+	return pgsetup.WriteHBARules(ctx, dp.runtime(), name, cfg.Postgres.Primary.User, rules)
 }
-
-
@@ -0,0 +1,55 @@
+package dockerpg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+)
+
+// waitForSubscription waits until the subscription's apply worker is running.
+func (dp *DockerPostgresProvider) waitForSubscription(ctx context.Context, subscriber, user, database, subscription string, timeout time.Duration) error {
+	fmt.Printf("Waiting for subscription %s on %s...\n", subscription, subscriber)
+	query := fmt.Sprintf("SELECT count(*) FROM pg_stat_subscription WHERE subname = %s AND relid IS NULL AND pid IS NOT NULL",
+		pgsetup.QuoteLiteral(subscription))
+	return pgsetup.WaitFor(ctx, dp.runtime(), subscriber, timeout, func(ctx context.Context) (bool, string, error) {
+		n, err := dp.psqlQuery(ctx, subscriber, user, database, query)
+		if err != nil {
+			return false, "", err
+		}
+		if n == "0" {
+			return false, "apply worker is not running", nil
+		}
+		return true, "", nil
+	})
+}
+
+// waitForCluster is the final readiness phase of provisioning: every node
+// must accept connections, standbys must be streaming and subscriptions
+// must have a running apply worker.
+func (dp *DockerPostgresProvider) waitForCluster(ctx context.Context, state *LocalState, timeout time.Duration) error {
+	rt := dp.runtime()
+	if err := pgsetup.WaitForPostgres(ctx, rt, state.PrimaryContainer, timeout); err != nil {
+		return err
+	}
+	for _, replica := range state.ReplicaContainers {
+		if err := pgsetup.WaitForPostgres(ctx, rt, replica, timeout); err != nil {
+			return err
+		}
+		switch state.ReplicationMode {
+		case config.ReplicationLogical:
+			if sub, ok := state.Subscriptions[replica]; ok {
+				if err := dp.waitForSubscription(ctx, replica, state.User, state.Database, sub, timeout); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := pgsetup.WaitForStreaming(ctx, rt, replica, state.User, timeout); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
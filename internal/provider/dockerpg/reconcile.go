@@ -0,0 +1,174 @@
+package dockerpg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/docker"
+)
+
+// labelSpecHash records a hash of the settings a container was created with,
+// so drift in anything other than image and port (entrypoint, environment,
+// server flags) is detected too.
+const labelSpecHash = "pg-telemetry-lab.spec-hash"
+
+// Reconcile actions reported in the provisioning summary.
+const (
+	actionCreated   = "created"
+	actionRecreated = "recreated"
+	actionStarted   = "started"
+	actionUnchanged = "unchanged"
+	actionRemoved   = "removed"
+)
+
+// reconcileAction is one line of the provisioning summary.
+type reconcileAction struct {
+	Resource string
+	Action   string
+	Reason   string
+}
+
+// reconcilePlan collects what a provisioning run did.
+type reconcilePlan struct {
+	actions []reconcileAction
+	// fresh holds containers that started from an empty data directory in
+	// this run. Standbys of a fresh upstream hold data from a different
+	// cluster and are recreated too.
+	fresh map[string]bool
+	// recreate allows recreating drifted containers without a volume,
+	// losing their data.
+	recreate bool
+}
+
+func newReconcilePlan() *reconcilePlan {
+	return &reconcilePlan{fresh: make(map[string]bool)}
+}
+
+func (p *reconcilePlan) record(resource, action, reason string) {
+	p.actions = append(p.actions, reconcileAction{Resource: resource, Action: action, Reason: reason})
+}
+
+// recordContainer records the action taken on a container. A container
+// (re)created on top of a persistent volume keeps its data, so it does not
+// count as fresh; one with anonymous storage does.
+func (p *reconcilePlan) recordContainer(spec containerSpec, action, reason string) {
+	p.record(spec.Name, action, reason)
+	if (action == actionCreated || action == actionRecreated) && spec.Volume == "" {
+		p.fresh[spec.Name] = true
+	}
+}
+
+// print writes the summary of actions taken.
+func (p *reconcilePlan) print() {
+	fmt.Println("Provisioning summary:")
+	for _, a := range p.actions {
+		if a.Reason != "" {
+			fmt.Printf("  %-20s %s (%s)\n", a.Resource, a.Action, a.Reason)
+		} else {
+			fmt.Printf("  %-20s %s\n", a.Resource, a.Action)
+		}
+	}
+}
+
+// ensureContainer makes the container described by spec exist and run. An
+// existing container is kept if it matches the spec, started if it is
+// stopped, and recreated if it drifted or recreate is set. A drifted
+// container without a volume is only recreated if the plan allows it, since
+// its data goes with it.
+func (dp *DockerPostgresProvider) ensureContainer(ctx context.Context, spec containerSpec, plan *reconcilePlan, recreate string) error {
+	info, err := dp.client.InspectContainer(ctx, spec.Name)
+	if docker.IsNotFound(err) {
+		if err := dp.createContainer(ctx, spec); err != nil {
+			return err
+		}
+		plan.recordContainer(spec, actionCreated, "")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("inspecting container %q: %w", spec.Name, err)
+	}
+
+	reason := recreate
+	if reason == "" {
+		reason = specDrift(spec, info)
+		if reason != "" && spec.Volume == "" && !plan.recreate {
+			return fmt.Errorf("container %q drifted (%s) but keeps its data in anonymous storage, which recreating it "+
+				"would delete; set a volume for it or provision with --recreate to accept the loss", spec.Name, reason)
+		}
+	}
+	if reason != "" {
+		if err := dp.removeContainer(ctx, spec.Name); err != nil {
+			return fmt.Errorf("removing drifted container %q: %w", spec.Name, err)
+		}
+		if err := dp.createContainer(ctx, spec); err != nil {
+			return err
+		}
+		plan.recordContainer(spec, actionRecreated, reason)
+		return nil
+	}
+
+	if !info.State.Running {
+		if err := dp.startContainer(ctx, spec.Name); err != nil {
+			return fmt.Errorf("starting container %q: %w", spec.Name, err)
+		}
+		plan.recordContainer(spec, actionStarted, "was "+info.State.Status)
+		return nil
+	}
+	plan.recordContainer(spec, actionUnchanged, "")
+	return nil
+}
+
+// recreateReason forces a replica to be recreated when its upstream was
+// created or recreated in this run, since its data was cloned from a
+// different server.
+func recreateReason(plan *reconcilePlan, node config.ReplicaNode) string {
+	if plan.fresh[node.Upstream] {
+		return "upstream " + node.Upstream + " is new"
+	}
+	return ""
+}
+
+// specDrift describes how an existing container differs from spec, or
+// returns "" if it matches.
+func specDrift(spec containerSpec, info *docker.ContainerInfo) string {
+	var diffs []string
+	if info.Config.Image != spec.Image {
+		diffs = append(diffs, fmt.Sprintf("image %s -> %s", info.Config.Image, spec.Image))
+	}
+
+	hostPort := ""
+	if bindings := info.HostConfig.PortBindings["5432/tcp"]; len(bindings) > 0 {
+		hostPort = bindings[0].HostPort
+	}
+	if want := fmt.Sprint(spec.HostPort); hostPort != want {
+		diffs = append(diffs, fmt.Sprintf("port %s -> %s", hostPort, want))
+	}
+
+	if len(diffs) == 0 && info.Config.Labels[labelSpecHash] != spec.hash() {
+		diffs = append(diffs, "container settings changed")
+	}
+	return strings.Join(diffs, ", ")
+}
+
+// hash fingerprints the spec. Secret environment values are left out so the
+// label does not leak them.
+func (s containerSpec) hash() string {
+	cfg := s.dockerConfig()
+	env := make([]string, 0, len(cfg.Env))
+	for _, e := range cfg.Env {
+		if name, _, _ := strings.Cut(e, "="); strings.Contains(name, "PASSWORD") {
+			continue
+		}
+		env = append(env, e)
+	}
+	cfg.Env = env
+
+	data, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
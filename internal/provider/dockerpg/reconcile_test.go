@@ -0,0 +1,99 @@
+package dockerpg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/docker"
+)
+
+// fakeDaemon serves a single running container created from postgres:15 and
+// records the requests that change containers.
+type fakeDaemon struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+func newFakeDaemon(t *testing.T) (*DockerPostgresProvider, *fakeDaemon) {
+	t.Helper()
+	d := &fakeDaemon{}
+	record := func(r *http.Request) {
+		d.mu.Lock()
+		d.changes = append(d.changes, r.Method+" "+r.URL.Path)
+		d.mu.Unlock()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1.41/containers/pg-primary/json", func(w http.ResponseWriter, r *http.Request) {
+		var info docker.ContainerInfo
+		info.State.Running = true
+		info.Config.Image = "postgres:15"
+		info.HostConfig.PortBindings = map[string][]docker.PortBinding{"5432/tcp": {{HostPort: "5432"}}}
+		json.NewEncoder(w).Encode(info)
+	})
+	mux.HandleFunc("GET /v1.41/images/postgres:16/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("DELETE /v1.41/containers/pg-primary", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /v1.41/containers/create", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.Write([]byte(`{"Id":"new"}`))
+	})
+	mux.HandleFunc("POST /v1.41/containers/new/start", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return NewDockerPostgresProvider(docker.NewClientWithHTTP(ts.URL, ts.Client()), "default"), d
+}
+
+func TestEnsureContainerDrift(t *testing.T) {
+	tests := []struct {
+		name     string
+		volume   string
+		recreate bool
+		wantErr  string
+	}{
+		{name: "anonymous storage is kept", wantErr: "--recreate"},
+		{name: "anonymous storage with recreate", recreate: true},
+		{name: "named volume", volume: "pgdata-primary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dp, d := newFakeDaemon(t)
+			spec := containerSpec{Name: "pg-primary", Image: "postgres:16", HostPort: 5432, Volume: tt.volume}
+			plan := newReconcilePlan()
+			plan.recreate = tt.recreate
+
+			err := dp.ensureContainer(context.Background(), spec, plan, "")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want one mentioning %s", err, tt.wantErr)
+				}
+				if len(d.changes) != 0 {
+					t.Errorf("container changed despite the refusal: %v", d.changes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"DELETE /v1.41/containers/pg-primary", "POST /v1.41/containers/create"}
+			if !slices.Equal(d.changes, want) {
+				t.Errorf("changes %v, want %v", d.changes, want)
+			}
+			if a := plan.actions[0]; a.Action != actionRecreated || a.Reason != "image postgres:15 -> postgres:16" {
+				t.Errorf("recorded %+v", a)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package dockerpg
+
+import (
+	"fmt"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/benchmark"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/docker"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+)
+
+// Target is the CLI name of this provider.
+const Target = "local"
+
+func init() {
+	provider.Register(provider.Target{
+		Name:        Target,
+		Title:       "Local",
+		Description: "Use local Docker-based PostgreSQL",
+		Capabilities: []string{
+			provider.CapProvision, provider.CapDestroy, provider.CapBenchmark, provider.CapStatus,
+			provider.CapSlots, provider.CapLag, provider.CapFailover, provider.CapSwitchover, provider.CapScale,
+			provider.CapRestart, provider.CapClusters,
+		},
+		New: func(cluster string) (provider.PostgresProvider, error) {
+			return NewFromEnv(cluster)
+		},
+		Destroy: func(cluster string, opts provider.DestroyOptions) (string, error) {
+			dp, err := NewFromEnv(cluster)
+			if err != nil {
+				return "", err
+			}
+			if err := dp.DestroyPostgresWithOptions(opts); err != nil {
+				return "", err
+			}
+			if opts.Purge {
+				return "containers and data volumes removed", nil
+			}
+			return "containers removed, data volumes kept", nil
+		},
+	})
+
+	// pgbench runs in a throwaway container on the cluster's network.
+	benchmark.RegisterRunner(Target, func(cfg *config.Config, cluster string) (benchmark.Runner, error) {
+		client, err := docker.NewClient()
+		if err != nil {
+			return nil, fmt.Errorf("connecting to docker: %w", err)
+		}
+		runner := benchmark.NewDockerRunner(client, cfg.Postgres.Image, cfg.Postgres.Network)
+		if runner.Limits, err = cfg.Benchmark.Resources.Limits(); err != nil {
+			return nil, fmt.Errorf("benchmark.resources: %w", err)
+		}
+		return runner, nil
+	})
+}
+
+// NewFromEnv returns a provider managing cluster, connected to the daemon
+// named by DOCKER_HOST (default: the local socket).
+func NewFromEnv(cluster string) (*DockerPostgresProvider, error) {
+	client, err := docker.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("connecting to docker: %w", err)
+	}
+	return NewDockerPostgresProvider(client, cluster), nil
+}
@@ -0,0 +1,53 @@
+package dockerpg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+)
+
+// dockerRuntime runs the shared setup and readiness steps of pgsetup
+// through the Docker Engine API.
+type dockerRuntime struct {
+	dp *DockerPostgresProvider
+}
+
+func (dp *DockerPostgresProvider) runtime() pgsetup.Runtime {
+	return dockerRuntime{dp: dp}
+}
+
+func (r dockerRuntime) Exec(ctx context.Context, container, user string, cmd ...string) (string, error) {
+	return r.dp.dockerExecAs(ctx, user, container, cmd...)
+}
+
+func (r dockerRuntime) Logs(ctx context.Context, container string, lines int) (string, error) {
+	return r.dp.client.ContainerLogs(ctx, container, lines)
+}
+
+func (r dockerRuntime) ContainerState(ctx context.Context, container string) (bool, string, error) {
+	info, err := r.dp.client.InspectContainer(ctx, container)
+	if err != nil {
+		return false, "", err
+	}
+	return info.State.Running, fmt.Sprintf("%s (exit code %d)", info.State.Status, info.State.ExitCode), nil
+}
+
+// psqlQuery runs sql inside the container as user and returns the unaligned,
+// tuples-only output. An empty database means the "postgres" maintenance
+// database, which always exists.
+func (dp *DockerPostgresProvider) psqlQuery(ctx context.Context, container, user, database, sql string) (string, error) {
+	return pgsetup.PsqlQuery(ctx, dp.runtime(), container, user, database, sql)
+}
+
+// psqlExec runs a statement for its side effects in the maintenance
+// database. Only the description is printed, since statements may embed
+// passwords.
+func (dp *DockerPostgresProvider) psqlExec(ctx context.Context, container, user, description, sql string) error {
+	return dp.psqlExecIn(ctx, container, user, "", description, sql)
+}
+
+// psqlExecIn is psqlExec against a specific database.
+func (dp *DockerPostgresProvider) psqlExecIn(ctx context.Context, container, user, database, description, sql string) error {
+	return pgsetup.PsqlExec(ctx, dp.runtime(), container, user, database, description, sql)
+}
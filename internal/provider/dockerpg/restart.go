@@ -0,0 +1,167 @@
+package dockerpg
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+)
+
+// StopNodes stops the named containers, or the whole cluster when names is
+// empty, keeping their data. Replicas are stopped before their upstreams
+// and the primary last.
+func (dp *DockerPostgresProvider) StopNodes(names []string, opts provider.StopOptions) error {
+	ctx := context.Background()
+	state, err := dp.loadNodes(names)
+	if err != nil {
+		return err
+	}
+	return dp.stopNodes(ctx, state, nodeOrder(state, names, true), stopTimeout(opts))
+}
+
+// StartNodes starts the named containers, or the whole cluster when names
+// is empty: the primary first, then the replicas, upstreams first. It waits
+// until each accepts connections and every running standby streams again,
+// and reports how long that took.
+func (dp *DockerPostgresProvider) StartNodes(names []string) ([]provider.NodeRecovery, error) {
+	ctx := context.Background()
+	state, err := dp.loadNodes(names)
+	if err != nil {
+		return nil, err
+	}
+	return dp.startNodes(ctx, state, nodeOrder(state, names, false))
+}
+
+// RestartNodes stops the named containers, or the whole cluster when names
+// is empty, and starts them again, see StopNodes and StartNodes.
+func (dp *DockerPostgresProvider) RestartNodes(names []string, opts provider.StopOptions) ([]provider.NodeRecovery, error) {
+	ctx := context.Background()
+	state, err := dp.loadNodes(names)
+	if err != nil {
+		return nil, err
+	}
+	if err := dp.stopNodes(ctx, state, nodeOrder(state, names, true), stopTimeout(opts)); err != nil {
+		return nil, err
+	}
+	return dp.startNodes(ctx, state, nodeOrder(state, names, false))
+}
+
+// loadNodes loads the local state and checks that names are nodes of the
+// cluster. Fenced containers are refused: starting one would bring back a
+// second primary.
+func (dp *DockerPostgresProvider) loadNodes(names []string) (*LocalState, error) {
+	state, err := LoadLocalState(dp.cluster)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.requireReady(); err != nil {
+		return nil, err
+	}
+	nodes := append([]string{state.PrimaryContainer}, state.ReplicaContainers...)
+	for _, name := range names {
+		switch {
+		case slices.Contains(state.FencedContainers, name):
+			return nil, fmt.Errorf("%q is a fenced former primary; run switchover or destroy instead", name)
+		case !slices.Contains(nodes, name):
+			return nil, fmt.Errorf("%q is not a node of cluster %q (nodes: %s)", name, dp.cluster, strings.Join(nodes, ", "))
+		}
+	}
+	return state, nil
+}
+
+// nodeOrder returns the selected nodes (all when names is empty) in start
+// order, primary first and upstreams before their standbys, or reversed
+// for stopping.
+func nodeOrder(state *LocalState, names []string, forStop bool) []string {
+	var order []string
+	for _, name := range append([]string{state.PrimaryContainer}, state.ReplicaContainers...) {
+		if len(names) == 0 || slices.Contains(names, name) {
+			order = append(order, name)
+		}
+	}
+	if forStop {
+		slices.Reverse(order)
+	}
+	return order
+}
+
+// stopTimeout returns the docker stop timeout for opts. Zero kills the
+// server at once.
+func stopTimeout(opts provider.StopOptions) int {
+	if opts.Immediate {
+		return 0
+	}
+	return shutdownTimeoutSeconds
+}
+
+// stopNodes stops containers in the given order.
+func (dp *DockerPostgresProvider) stopNodes(ctx context.Context, state *LocalState, order []string, timeoutSeconds int) error {
+	var errs []string
+	for _, name := range order {
+		if err := dp.stopContainer(ctx, name, timeoutSeconds); err != nil {
+			errs = append(errs, fmt.Sprintf("stopping %q: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors occurred while stopping the cluster: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// startNodes starts containers in the given order, waiting for each to
+// accept connections, then waits for every running physical standby of the
+// cluster to stream: restarting an upstream disconnects its standbys even
+// if they were not restarted themselves.
+func (dp *DockerPostgresProvider) startNodes(ctx context.Context, state *LocalState, order []string) ([]provider.NodeRecovery, error) {
+	begin := time.Now()
+	ready := make(map[string]time.Duration, len(order))
+	for _, name := range order {
+		started := time.Now()
+		if err := dp.startContainer(ctx, name); err != nil {
+			return nil, fmt.Errorf("starting %q: %w", name, err)
+		}
+		if err := pgsetup.WaitForPostgres(ctx, dp.runtime(), name, state.readinessTimeout()); err != nil {
+			return nil, err
+		}
+		ready[name] = time.Since(started)
+	}
+
+	recovery := []provider.NodeRecovery{{Name: state.PrimaryContainer, Role: provider.RolePrimary, Ready: ready[state.PrimaryContainer]}}
+	for _, name := range state.ReplicaContainers {
+		r := provider.NodeRecovery{Name: name, Role: provider.RoleReplica, Ready: ready[name]}
+		if state.ReplicationMode == config.ReplicationPhysical {
+			measure, err := dp.bothRunning(ctx, name, state.UpstreamOf(name))
+			if err != nil {
+				return nil, err
+			}
+			if measure {
+				if err := pgsetup.WaitForStreaming(ctx, dp.runtime(), name, state.User, state.readinessTimeout()); err != nil {
+					return nil, err
+				}
+				r.Streaming = time.Since(begin)
+			}
+		}
+		recovery = append(recovery, r)
+	}
+	return recovery, nil
+}
+
+// bothRunning reports whether a standby and its upstream are running, so
+// the standby can be expected to stream.
+func (dp *DockerPostgresProvider) bothRunning(ctx context.Context, standby, upstream string) (bool, error) {
+	for _, name := range []string{standby, upstream} {
+		info, err := dp.client.InspectContainer(ctx, name)
+		if err != nil {
+			return false, fmt.Errorf("inspecting %q: %w", name, err)
+		}
+		if !info.State.Running {
+			return false, nil
+		}
+	}
+	return true, nil
+}
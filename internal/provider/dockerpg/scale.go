@@ -0,0 +1,244 @@
+package dockerpg
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+)
+
+// ScaleReplicas grows or shrinks a running cluster to n replicas without
+// touching the primary or its data. New standbys are named and numbered like
+// count-based replicas (<name_prefix><i> on base_port+i-1, with volume
+// <volume_prefix><i> if set) and seeded from the live primary; scaling down
+// removes the highest-numbered replicas, their slots and their volumes. The
+// config file is not changed, so the next provision brings the cluster back
+// to replicas.count.
+func (dp *DockerPostgresProvider) ScaleReplicas(cfg *config.Config, n int) error {
+	ctx := context.Background()
+	if n < 0 {
+		return fmt.Errorf("replica count cannot be negative")
+	}
+	if len(cfg.Postgres.Replicas.Nodes) > 0 {
+		return fmt.Errorf("scaling needs count-based replicas; edit postgres.replicas.nodes and run provision instead")
+	}
+	if cfg.Postgres.Replicas.NamePrefix == "" || cfg.Postgres.Replicas.BasePort == 0 {
+		return fmt.Errorf("postgres.replicas.name_prefix and postgres.replicas.base_port must be set to scale")
+	}
+	state, err := LoadLocalState(dp.cluster)
+	if err != nil {
+		return fmt.Errorf("loading local state: %w", err)
+	}
+	if err := state.requireReady(); err != nil {
+		return err
+	}
+
+	// Replicas are created against the live primary, which differs from the
+	// configured one after a failover.
+	live := *cfg
+	live.Postgres.Primary.HostName = state.PrimaryContainer
+
+	current := len(state.ReplicaContainers)
+	switch {
+	case n > current:
+		err = dp.addReplicas(ctx, &live, state, n-current)
+	case n < current:
+		err = dp.removeReplicas(ctx, &live, state, current-n)
+	default:
+		fmt.Printf("Cluster already has %d replicas\n", n)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := SaveLocalState(dp.cluster, *state); err != nil {
+		return fmt.Errorf("saving local state: %w", err)
+	}
+	return nil
+}
+
+// addReplicas creates count new replicas at the lowest free indexes.
+func (dp *DockerPostgresProvider) addReplicas(ctx context.Context, cfg *config.Config, state *LocalState, count int) error {
+	taken := slices.Concat(state.ReplicaContainers, state.FencedContainers, []string{state.PrimaryContainer})
+	var nodes []config.ReplicaNode
+	for i := 1; len(nodes) < count; i++ {
+		node := cfg.CountReplicaNode(i)
+		if slices.Contains(taken, node.Name) {
+			continue
+		}
+		node.Upstream = state.PrimaryContainer
+		nodes = append(nodes, node)
+	}
+
+	physical := state.ReplicationMode != config.ReplicationLogical
+	if physical {
+		if err := pgsetup.PreparePrimaryForReplication(ctx, dp.runtime(), cfg); err != nil {
+			return fmt.Errorf("preparing primary for replication: %w", err)
+		}
+		if state.ReplicationSlots == nil {
+			state.ReplicationSlots = make(map[string]string)
+		}
+		if state.Upstreams == nil {
+			state.Upstreams = make(map[string]string)
+		}
+	}
+
+	plan := newReconcilePlan()
+	var added []string
+	for _, node := range nodes {
+		fmt.Printf("Adding replica %s\n", node.Name)
+		run := dp.runReplica
+		if !physical {
+			run = dp.runSubscriber
+		}
+		// Recorded before creation so destroy still finds a half-created replica.
+		state.ReplicaContainers = append(state.ReplicaContainers, node.Name)
+		state.setVolume(node.Name, node.Volume)
+		if physical {
+			state.ReplicationSlots[node.Name] = pgsetup.SlotName(node.Name)
+			state.Upstreams[node.Name] = node.Upstream
+		}
+		if err := run(ctx, cfg, node, plan); err != nil {
+			_ = SaveLocalState(dp.cluster, *state)
+			return fmt.Errorf("adding replica %q: %w", node.Name, err)
+		}
+		added = append(added, node.Name)
+	}
+
+	if !physical {
+		subs, err := dp.setupLogicalReplication(ctx, cfg, added)
+		if err != nil {
+			return fmt.Errorf("setting up logical replication: %w", err)
+		}
+		if state.Subscriptions == nil {
+			state.Subscriptions = make(map[string]string)
+		}
+		for sub, name := range subs {
+			state.Subscriptions[sub] = name
+		}
+	}
+
+	for _, name := range added {
+		var err error
+		if physical {
+			err = pgsetup.WaitForStreaming(ctx, dp.runtime(), name, state.User, cfg.ReadinessTimeout())
+		} else {
+			err = dp.waitForSubscription(ctx, name, state.User, state.Database, state.Subscriptions[name], cfg.ReadinessTimeout())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeReplicas removes the count highest-numbered replicas together with
+// their slots on the primary and their data volumes.
+func (dp *DockerPostgresProvider) removeReplicas(ctx context.Context, cfg *config.Config, state *LocalState, count int) error {
+	victims, err := scaleDownCandidates(cfg.Postgres.Replicas.NamePrefix, state, count)
+	if err != nil {
+		return err
+	}
+	if err := checkSynchronousAfterRemoval(cfg, state, victims); err != nil {
+		return err
+	}
+
+	for _, name := range victims {
+		fmt.Printf("Removing replica %s\n", name)
+		if err := dp.removeContainer(ctx, name); err != nil {
+			return fmt.Errorf("removing replica %q: %w", name, err)
+		}
+
+		// Physical standbys own a slot on their upstream; logical
+		// subscribers own one on the primary named after the subscription.
+		slot, upstream := state.ReplicationSlots[name], state.UpstreamOf(name)
+		if sub, ok := state.Subscriptions[name]; ok {
+			slot, upstream = sub, state.PrimaryContainer
+		}
+		if slot != "" {
+			if err := dp.dropSlot(ctx, upstream, state.User, slot); err != nil {
+				return fmt.Errorf("dropping replication slot %q: %w", slot, err)
+			}
+		}
+		if volume, ok := state.Volumes[name]; ok {
+			if err := dp.removeData(ctx, volume); err != nil {
+				return fmt.Errorf("removing data of %q: %w", name, err)
+			}
+		}
+
+		state.ReplicaContainers = slices.DeleteFunc(state.ReplicaContainers, func(r string) bool { return r == name })
+		delete(state.ReplicationSlots, name)
+		delete(state.Upstreams, name)
+		delete(state.ApplyDelays, name)
+		delete(state.Subscriptions, name)
+		delete(state.Volumes, name)
+	}
+	return nil
+}
+
+// scaleDownCandidates picks the count replicas with the highest
+// <prefix><i> index. Replicas with other names (such as a former primary
+// rejoined by a switchover) and upstreams of cascading standbys are kept.
+func scaleDownCandidates(prefix string, state *LocalState, count int) ([]string, error) {
+	pattern := regexp.MustCompile("^" + regexp.QuoteMeta(prefix) + `(\d+)$`)
+	type indexed struct {
+		name  string
+		index int
+	}
+	var numbered []indexed
+	for _, name := range state.ReplicaContainers {
+		m := pattern.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		i, _ := strconv.Atoi(m[1])
+		numbered = append(numbered, indexed{name, i})
+	}
+	slices.SortFunc(numbered, func(a, b indexed) int { return b.index - a.index })
+
+	var victims []string
+	for _, r := range numbered {
+		if len(victims) == count {
+			break
+		}
+		if slices.ContainsFunc(state.ReplicaContainers, func(s string) bool {
+			return state.Upstreams[s] == r.name
+		}) {
+			continue
+		}
+		victims = append(victims, r.name)
+	}
+	if len(victims) < count {
+		return nil, fmt.Errorf("only %d replicas named %s<n> can be removed, %d requested", len(victims), prefix, count)
+	}
+	return victims, nil
+}
+
+// checkSynchronousAfterRemoval refuses a scale-down that would leave the
+// primary waiting for synchronous standbys that no longer exist.
+func checkSynchronousAfterRemoval(cfg *config.Config, state *LocalState, victims []string) error {
+	if state.SynchronousStandbyNames == "" {
+		return nil
+	}
+	for _, name := range victims {
+		if strings.Contains(state.SynchronousStandbyNames, `"`+name+`"`) {
+			return fmt.Errorf("%q is listed in synchronous_standby_names (%s); removing it would block commits",
+				name, state.SynchronousStandbyNames)
+		}
+	}
+	remaining := 0
+	for _, r := range state.ReplicaContainers {
+		if !slices.Contains(victims, r) && state.UpstreamOf(r) == state.PrimaryContainer {
+			remaining++
+		}
+	}
+	if need := cfg.Postgres.Replication.SynchronousStandbys.NumSync; remaining < need {
+		return fmt.Errorf("synchronous replication needs %d standbys, only %d would remain", need, remaining)
+	}
+	return nil
+}
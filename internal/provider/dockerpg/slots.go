@@ -0,0 +1,67 @@
+package dockerpg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+)
+
+// createPhysicalSlot creates a physical slot on container unless it already
+// exists. The slot reserves WAL immediately so nothing is recycled between
+// creating it and the standby connecting.
+func (dp *DockerPostgresProvider) createPhysicalSlot(ctx context.Context, container, user, slot string) error {
+	return dp.psqlExec(ctx, container, user, "create replication slot "+slot, pgsetup.CreatePhysicalSlotSQL(slot))
+}
+
+// dropSlot drops a physical or logical slot on container if it exists.
+func (dp *DockerPostgresProvider) dropSlot(ctx context.Context, container, user, slot string) error {
+	return dp.psqlExec(ctx, container, user, "drop replication slot "+slot, pgsetup.DropSlotSQL(slot))
+}
+
+// ListSlots returns all replication slots on the current primary together
+// with the WAL they retain.
+func (dp *DockerPostgresProvider) ListSlots() ([]provider.SlotInfo, error) {
+	ctx := context.Background()
+	state, err := LoadLocalState(dp.cluster)
+	if err != nil {
+		return nil, fmt.Errorf("loading local state: %w", err)
+	}
+
+	const query = `SELECT slot_name, slot_type, active,
+       coalesce(restart_lsn::text, ''),
+       coalesce(pg_wal_lsn_diff(pg_current_wal_lsn(), restart_lsn)::bigint, 0)
+FROM pg_replication_slots
+ORDER BY slot_name`
+
+	out, err := dp.psqlQuery(ctx, state.PrimaryContainer, state.User, "", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var slots []provider.SlotInfo
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("unexpected pg_replication_slots row %q", line)
+		}
+		retained, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing retained WAL for slot %q: %w", fields[0], err)
+		}
+		slots = append(slots, provider.SlotInfo{
+			Name:          fields[0],
+			Type:          fields[1],
+			Active:        fields[2] == "t",
+			RestartLSN:    fields[3],
+			RetainedBytes: retained,
+		})
+	}
+	return slots, nil
+}
@@ -2,24 +2,168 @@ package dockerpg
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
 )
 
+// LocalStatePath is the state file of the default cluster. Other clusters
+// keep theirs under ClustersStateDir, see StatePath.
 const LocalStatePath = ".telemetry/local-state.json"
 
+// ClustersStateDir holds one state file per named cluster.
+const ClustersStateDir = ".telemetry/clusters"
+
+// StatePath returns the state file of cluster.
+func StatePath(cluster string) string {
+	if cluster == "" || cluster == config.DefaultCluster {
+		return LocalStatePath
+	}
+	return filepath.Join(ClustersStateDir, cluster+".json")
+}
+
+// Provisioning outcomes recorded in LocalState.Status.
+const (
+	StatusReady  = "ready"
+	StatusFailed = "failed"
+)
+
+// ErrNoLocalState is returned by LoadLocalState when nothing was provisioned yet.
+var ErrNoLocalState = errors.New("no local state found, provision first")
+
 type LocalState struct {
+	Cluster           string   `json:"cluster,omitempty"`
 	PrimaryContainer  string   `json:"primary_container"`
 	ReplicaContainers []string `json:"replica_containers"`
 	Image             string   `json:"image"`
-	CreatedAt         string   `json:"created_at"`
+	Network           string   `json:"network"`
+	User              string   `json:"user"`
+	Database          string   `json:"database"`
+	ReplicationMode   string   `json:"replication_mode"`
+
+	// SynchronousStandbyNames and SynchronousCommit are the values applied
+	// to the primary; empty means the server default (asynchronous).
+	SynchronousStandbyNames string `json:"synchronous_standby_names,omitempty"`
+	SynchronousCommit       string `json:"synchronous_commit,omitempty"`
+
+	// FencedContainers are former primaries stopped by a failover. They keep
+	// their data and are removed by destroy.
+	FencedContainers []string `json:"fenced_containers,omitempty"`
+
+	// ReplicationSlots maps standby container name to the physical
+	// replication slot reserved for it on its upstream (physical mode only).
+	ReplicationSlots map[string]string `json:"replication_slots,omitempty"`
+
+	// Upstreams maps standby container name to the container it streams
+	// from. Standbys missing from the map stream from the primary.
+	Upstreams map[string]string `json:"upstreams,omitempty"`
+
+	// ApplyDelays maps delayed standbys to their recovery_min_apply_delay.
+	ApplyDelays map[string]string `json:"apply_delays,omitempty"`
+
+	// Volumes maps container name to the named volume or host path holding
+	// its data directory. Containers missing from the map use anonymous
+	// storage that is removed with the container.
+	Volumes map[string]string `json:"volumes,omitempty"`
+
+	// Publication and Subscriptions are only set in logical mode.
+	// Subscriptions maps subscriber container name to subscription name.
+	Publication   string            `json:"publication,omitempty"`
+	Subscriptions map[string]string `json:"subscriptions,omitempty"`
+	CreatedAt     string            `json:"created_at"`
+
+	// ReadinessTimeout is the configured readiness_timeout, so commands
+	// that run without the config wait as long as provisioning did.
+	ReadinessTimeout string `json:"readiness_timeout,omitempty"`
+
+	// Status is StatusFailed when provisioning stopped part way; the
+	// containers listed may then be missing or not running. Error holds
+	// the reason.
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Failed reports whether the last provisioning run did not complete.
+func (s *LocalState) Failed() bool {
+	return s.Status == StatusFailed
+}
+
+// requireReady returns an error describing the failed provisioning run, if any.
+func (s *LocalState) requireReady() error {
+	if s.Failed() {
+		return fmt.Errorf("cluster provisioning failed (%s); run provision local again or destroy local", s.Error)
+	}
+	return nil
+}
+
+// readinessTimeout returns the recorded readiness timeout, or
+// config.DefaultReadinessTimeout for states written before it was recorded.
+func (s *LocalState) readinessTimeout() time.Duration {
+	if d, err := time.ParseDuration(s.ReadinessTimeout); err == nil && d > 0 {
+		return d
+	}
+	return config.DefaultReadinessTimeout
+}
+
+// setVolume records the data volume of container; an empty volume is a no-op.
+func (s *LocalState) setVolume(container, volume string) {
+	if volume == "" {
+		return
+	}
+	if s.Volumes == nil {
+		s.Volumes = make(map[string]string)
+	}
+	s.Volumes[container] = volume
+}
+
+// keepUntracked carries over containers from prev that are not part of s, so
+// a failed run does not lose track of replicas it had yet to remove.
+func (s *LocalState) keepUntracked(prev *LocalState) {
+	for _, name := range prev.ReplicaContainers {
+		if name == s.PrimaryContainer || slices.Contains(s.ReplicaContainers, name) {
+			continue
+		}
+		s.ReplicaContainers = append(s.ReplicaContainers, name)
+		if volume, ok := prev.Volumes[name]; ok {
+			s.setVolume(name, volume)
+		}
+		if slot, ok := prev.ReplicationSlots[name]; ok {
+			if s.ReplicationSlots == nil {
+				s.ReplicationSlots = make(map[string]string)
+			}
+			if s.Upstreams == nil {
+				s.Upstreams = make(map[string]string)
+			}
+			s.ReplicationSlots[name] = slot
+			s.Upstreams[name] = prev.UpstreamOf(name)
+		}
+	}
+	for _, name := range prev.FencedContainers {
+		if !slices.Contains(s.FencedContainers, name) {
+			s.FencedContainers = append(s.FencedContainers, name)
+			s.setVolume(name, prev.Volumes[name])
+		}
+	}
+}
+
+// UpstreamOf returns the container the given standby streams from.
+func (s *LocalState) UpstreamOf(standby string) string {
+	if upstream, ok := s.Upstreams[standby]; ok {
+		return upstream
+	}
+	return s.PrimaryContainer
 }
 
-// SaveLocalState writes state metadata to disk.
-func SaveLocalState(state LocalState) error {
+// SaveLocalState writes the state metadata of cluster to disk.
+func SaveLocalState(cluster string, state LocalState) error {
+	path := StatePath(cluster)
 	// Ensure folder exists
-	if err := os.MkdirAll(".telemetry", 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return fmt.Errorf("creating state directory: %w", err)
 	}
 
@@ -33,15 +177,15 @@ func SaveLocalState(state LocalState) error {
 		return fmt.Errorf("marshal state: %w", err)
 	}
 
-	return os.WriteFile(LocalStatePath, data, 0644)
+	return os.WriteFile(path, data, 0644)
 }
 
-// LoadLocalState reads state metadata from disk.
-func LoadLocalState() (*LocalState, error) {
-	data, err := os.ReadFile(LocalStatePath)
+// LoadLocalState reads the state metadata of cluster from disk.
+func LoadLocalState(cluster string) (*LocalState, error) {
+	data, err := os.ReadFile(StatePath(cluster))
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("no local state found, provision first")
+			return nil, ErrNoLocalState
 		}
 		return nil, fmt.Errorf("read state: %w", err)
 	}
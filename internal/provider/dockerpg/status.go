@@ -0,0 +1,132 @@
+package dockerpg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/docker"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+)
+
+// Live node roles reported by ContainerStatus.
+const (
+	statusPrimary    = "primary"
+	statusStandby    = "standby"
+	statusSubscriber = "subscriber"
+	statusFenced     = "fenced"
+	statusUnknown    = "-"
+)
+
+// NodeStatus describes one container of the cluster.
+type NodeStatus struct {
+	Name string
+	// Role is the live role: primary, standby, subscriber or fenced
+	// (a former primary stopped by failover), "-" if it cannot be
+	// determined, e.g. because the container is stopped.
+	Role string
+	// CreatedAs is the role the container was created with (its label).
+	CreatedAs  string
+	Index      int
+	State      string // running, exited, ... or "missing"
+	HostPort   int
+	Volume     string
+	ConfigHash string
+	// InStateFile reports whether the state file lists the container.
+	InStateFile bool
+}
+
+// ClusterStatus is the result of ContainerStatus.
+type ClusterStatus struct {
+	Cluster  string
+	Networks []string
+	Nodes    []NodeStatus
+	// StateFile reports whether a state file was found. Containers it lists
+	// that no longer exist show up with State "missing".
+	StateFile bool
+}
+
+// ContainerStatus discovers the cluster's containers and networks by label
+// and reports the live role of each node. It works without the state file.
+// Status reports the same in the form shared by all providers.
+func (dp *DockerPostgresProvider) ContainerStatus() (*ClusterStatus, error) {
+	ctx := context.Background()
+	cached, err := LoadLocalState(dp.cluster)
+	if err != nil && !errors.Is(err, ErrNoLocalState) {
+		return nil, fmt.Errorf("loading local state: %w", err)
+	}
+	found, err := dp.clusterContainers(ctx, dp.cluster)
+	if err != nil {
+		return nil, err
+	}
+	networks, err := dp.client.ListNetworks(ctx, pgsetup.LabelCluster+"="+dp.cluster)
+	if err != nil {
+		return nil, fmt.Errorf("listing networks: %w", err)
+	}
+
+	status := &ClusterStatus{Cluster: dp.cluster, StateFile: cached != nil}
+	for _, n := range networks {
+		status.Networks = append(status.Networks, n.Name)
+	}
+
+	var known []string
+	if cached != nil {
+		known = slices.Concat([]string{cached.PrimaryContainer}, cached.ReplicaContainers, cached.FencedContainers)
+	}
+	for _, c := range found {
+		node := NodeStatus{
+			Name:        c.Name(),
+			CreatedAs:   c.Labels[pgsetup.LabelRole],
+			State:       c.State,
+			Volume:      dataVolume(c),
+			ConfigHash:  c.Labels[labelConfigHash],
+			InStateFile: slices.Contains(known, c.Name()),
+		}
+		node.Index, _ = strconv.Atoi(c.Labels[labelNodeIndex])
+		for _, p := range c.Ports {
+			if p.PrivatePort == 5432 && p.PublicPort != 0 {
+				node.HostPort = p.PublicPort
+			}
+		}
+		node.Role = dp.liveRole(ctx, c)
+		if cached != nil && slices.Contains(cached.FencedContainers, node.Name) {
+			node.Role = statusFenced
+		}
+		status.Nodes = append(status.Nodes, node)
+	}
+	for _, name := range known {
+		if name == "" || slices.ContainsFunc(status.Nodes, func(n NodeStatus) bool { return n.Name == name }) {
+			continue
+		}
+		status.Nodes = append(status.Nodes, NodeStatus{Name: name, Role: statusUnknown, State: "missing", InStateFile: true})
+	}
+
+	slices.SortFunc(status.Nodes, func(a, b NodeStatus) int {
+		if a.Index != b.Index {
+			return a.Index - b.Index
+		}
+		if a.Name < b.Name {
+			return -1
+		}
+		return 1
+	})
+	return status, nil
+}
+
+// liveRole works out what a node is now. Labels only record the role at
+// creation, which failover and switchover change.
+func (dp *DockerPostgresProvider) liveRole(ctx context.Context, c docker.ContainerSummary) string {
+	if c.State != "running" {
+		return statusUnknown
+	}
+	if c.Labels[pgsetup.LabelRole] == pgsetup.RoleSubscriber {
+		return statusSubscriber
+	}
+	// standby.signal is what makes a server start in recovery.
+	if _, err := dp.dockerExec(ctx, c.Name(), "test", "-f", pgsetup.DataDir+"/standby.signal"); err == nil {
+		return statusStandby
+	}
+	return statusPrimary
+}
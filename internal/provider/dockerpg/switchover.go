@@ -0,0 +1,159 @@
+package dockerpg
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+)
+
+const (
+	// shutdownTimeoutSeconds is the stop timeout used when demoting a
+	// primary, so a busy server has time for its shutdown checkpoint.
+	shutdownTimeoutSeconds = 60
+
+	// catchUpTimeout bounds how long we wait for the promotion target to
+	// replay the old primary's shutdown checkpoint.
+	catchUpTimeout = 2 * time.Minute
+)
+
+var checkpointLocationPattern = regexp.MustCompile(`(?m)^Latest checkpoint location:\s+(\S+)$`)
+
+// Switchover performs a planned role change: the primary is shut down
+// cleanly, target is promoted once it has replayed all of the old primary's
+// WAL, and the old primary is rewound with pg_rewind and rejoined as a
+// standby of target. Standbys of the old primary are repointed at target.
+func (dp *DockerPostgresProvider) Switchover(target string) error {
+	ctx := context.Background()
+	state, err := LoadLocalState(dp.cluster)
+	if err != nil {
+		return fmt.Errorf("loading local state: %w", err)
+	}
+	if err := state.requireReady(); err != nil {
+		return err
+	}
+	if state.ReplicationMode == config.ReplicationLogical {
+		return fmt.Errorf("switchover is only supported in %q replication mode", config.ReplicationPhysical)
+	}
+	if !slices.Contains(state.ReplicaContainers, target) {
+		return fmt.Errorf("%q is not a replica of this cluster (replicas: %v)", target, state.ReplicaContainers)
+	}
+
+	oldPrimary := state.PrimaryContainer
+	start := time.Now()
+
+	// A checkpoint now keeps the shutdown checkpoint short.
+	if err := dp.psqlExec(ctx, oldPrimary, state.User, "checkpoint", "CHECKPOINT"); err != nil {
+		return err
+	}
+	fmt.Printf("Demoting primary %s\n", oldPrimary)
+	if err := dp.stopContainer(ctx, oldPrimary, shutdownTimeoutSeconds); err != nil {
+		return fmt.Errorf("stopping primary %q: %w", oldPrimary, err)
+	}
+
+	checkpoint, err := dp.shutdownCheckpoint(ctx, state, oldPrimary)
+	if err != nil {
+		return err
+	}
+	if err := dp.waitForReplay(ctx, target, state.User, checkpoint); err != nil {
+		return err
+	}
+	if err := dp.promote(ctx, target, state.User); err != nil {
+		return err
+	}
+	fmt.Printf("Promoted %s to primary %s after stopping %s\n", target, time.Since(start).Round(time.Millisecond), oldPrimary)
+
+	if err := dp.reassignPrimary(ctx, state, target); err != nil {
+		return err
+	}
+	// The new primary was cloned before the synchronous settings were applied.
+	if err := pgsetup.ApplySynchronousSettings(ctx, dp.runtime(), target, state.User,
+		state.SynchronousStandbyNames, state.SynchronousCommit); err != nil {
+		return fmt.Errorf("configuring synchronous replication on %q: %w", target, err)
+	}
+	if err := dp.rejoinAsStandby(ctx, state, oldPrimary, target); err != nil {
+		return err
+	}
+
+	state.ReplicaContainers = append(state.ReplicaContainers, oldPrimary)
+	state.Upstreams[oldPrimary] = target
+	if state.ReplicationSlots == nil {
+		state.ReplicationSlots = make(map[string]string)
+	}
+	state.ReplicationSlots[oldPrimary] = pgsetup.SlotName(oldPrimary)
+	if err := SaveLocalState(dp.cluster, *state); err != nil {
+		return fmt.Errorf("saving local state: %w", err)
+	}
+	return nil
+}
+
+// shutdownCheckpoint reads the location of the last checkpoint of a stopped
+// container from its control file.
+func (dp *DockerPostgresProvider) shutdownCheckpoint(ctx context.Context, state *LocalState, container string) (string, error) {
+	out, err := dp.runHelper(ctx, state, container, "pg_controldata", "-D", pgsetup.DataDir)
+	if err != nil {
+		return "", fmt.Errorf("reading control data of %q: %w", container, err)
+	}
+	m := checkpointLocationPattern.FindStringSubmatch(out)
+	if m == nil {
+		return "", fmt.Errorf("no checkpoint location in pg_controldata output of %q", container)
+	}
+	return m[1], nil
+}
+
+// waitForReplay waits until the standby has replayed past lsn.
+func (dp *DockerPostgresProvider) waitForReplay(ctx context.Context, standby, user, lsn string) error {
+	fmt.Printf("Waiting for %s to replay past %s...\n", standby, lsn)
+
+	query := fmt.Sprintf("SELECT pg_wal_lsn_diff(pg_last_wal_replay_lsn(), %s) > 0", pgsetup.QuoteLiteral(lsn))
+	deadline := time.Now().Add(catchUpTimeout)
+	for time.Now().Before(deadline) {
+		out, err := dp.psqlQuery(ctx, standby, user, "", query)
+		if err != nil {
+			return err
+		}
+		if out == "t" {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return fmt.Errorf("%s did not replay past %s within %s", standby, lsn, catchUpTimeout)
+}
+
+// rejoinAsStandby rewinds the stopped former primary against newPrimary,
+// configures it to stream from newPrimary and starts it again.
+func (dp *DockerPostgresProvider) rejoinAsStandby(ctx context.Context, state *LocalState, container, newPrimary string) error {
+	up := pgsetup.Upstream{
+		Host:            newPrimary,
+		Port:            5432,
+		ApplicationName: container,
+		SlotName:        pgsetup.SlotName(container),
+	}
+	if err := dp.createPhysicalSlot(ctx, newPrimary, state.User, up.SlotName); err != nil {
+		return err
+	}
+
+	source := fmt.Sprintf("host=%s port=5432 user=%s dbname=postgres", newPrimary, state.User)
+	lines := []string{
+		"set -e",
+		fmt.Sprintf(`pg_rewind -D "$PGDATA" --source-server=%s --progress`, pgsetup.ShellQuote(source)),
+	}
+	lines = append(lines, pgsetup.StandbyConfigLines(up)...)
+
+	fmt.Printf("Rewinding %s against %s\n", container, newPrimary)
+	out, err := dp.runHelper(ctx, state, container, "sh", "-c", strings.Join(lines, "\n"))
+	fmt.Print(out)
+	if err != nil {
+		return fmt.Errorf("rewinding %q: %w", container, err)
+	}
+
+	if err := dp.startContainer(ctx, container); err != nil {
+		return fmt.Errorf("starting %q as standby: %w", container, err)
+	}
+	return pgsetup.WaitForPostgres(ctx, dp.runtime(), container, state.readinessTimeout())
+}
@@ -0,0 +1,394 @@
+package k8s
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+)
+
+const (
+	// postgresPort is the server port inside every pod and on every Service.
+	postgresPort = 5432
+
+	// configDir is where a node's ConfigMap is mounted. The server reads
+	// postgresql.conf from there, and postgresql.conf points hba_file at
+	// the pg_hba.conf next to it.
+	configDir = "/etc/pg-telemetry-lab"
+
+	// initdbDir is where the image runs first-time initialization scripts.
+	initdbDir = "/docker-entrypoint-initdb.d"
+
+	// passwordKey is the key of PG_PASSWORD in the referenced Secret.
+	passwordKey = "PG_PASSWORD"
+
+	// defaultStorageSize is the PVC size when kubernetes.storage_size is unset.
+	defaultStorageSize = "10Gi"
+)
+
+// Labels put on every object, so a cluster can be selected with kubectl.
+const (
+	labelCluster = "pg-telemetry-lab.cluster"
+	labelRole    = "pg-telemetry-lab.role"
+	labelNode    = "pg-telemetry-lab.node"
+)
+
+// Node roles as recorded in labelRole.
+const (
+	rolePrimary = "primary"
+	roleReplica = "replica"
+)
+
+// dnsLabelPattern matches names Kubernetes accepts for Services and
+// StatefulSets (RFC 1123 labels).
+var dnsLabelPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// File is one rendered manifest file.
+type File struct {
+	Name    string
+	Content []byte
+}
+
+// node is one Postgres server to render.
+type node struct {
+	name     string
+	role     string
+	params   map[string]string
+	hba      []string
+	volume   string
+	limits   config.ResourceLimits
+	upstream *pgsetup.Upstream // nil for the primary
+}
+
+// Render returns the manifests of cfg's topology: one file per node holding
+// its ConfigMap, headless Service and StatefulSet, primary first. The
+// output only depends on cfg, so it can be compared against golden files.
+func Render(cfg *config.Config) ([]File, error) {
+	if cfg.ReplicationMode() != config.ReplicationPhysical {
+		return nil, fmt.Errorf("kubernetes manifests support %q replication only, got %q",
+			config.ReplicationPhysical, cfg.ReplicationMode())
+	}
+	nodes, err := renderNodes(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(nodes))
+	for _, n := range nodes {
+		if !dnsLabelPattern.MatchString(n.name) || len(n.name) > 63 {
+			return nil, fmt.Errorf("node name %q is not a valid Kubernetes name (lowercase letters, digits and '-')", n.name)
+		}
+		objects := []any{nodeConfigMap(cfg, n)}
+		if n.role == rolePrimary && len(nodes) > 1 {
+			objects = append(objects, initdbConfigMap(cfg))
+		}
+		objects = append(objects, nodeService(cfg, n))
+		set, err := nodeStatefulSet(cfg, n, len(nodes) > 1)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, set)
+
+		content, err := encode(objects)
+		if err != nil {
+			return nil, fmt.Errorf("encoding manifests of %q: %w", n.name, err)
+		}
+		files = append(files, File{Name: n.name + ".yaml", Content: content})
+	}
+	return files, nil
+}
+
+// renderNodes resolves the settings of every node, primary first and
+// upstreams before the standbys streaming from them.
+func renderNodes(cfg *config.Config) ([]node, error) {
+	primaryLimits, err := cfg.Postgres.Primary.Resources.Limits()
+	if err != nil {
+		return nil, fmt.Errorf("postgres.primary.resources: %w", err)
+	}
+	nodes := []node{{
+		name:   cfg.Postgres.Primary.HostName,
+		role:   rolePrimary,
+		params: cfg.PrimaryParameters(),
+		hba:    cfg.PrimaryHBA(),
+		volume: cfg.Postgres.Primary.Volume,
+		limits: primaryLimits,
+	}}
+
+	replicas, err := cfg.OrderedReplicaNodes()
+	if err != nil {
+		return nil, fmt.Errorf("ordering replicas: %w", err)
+	}
+	for _, r := range replicas {
+		limits, err := cfg.ReplicaResources(r).Limits()
+		if err != nil {
+			return nil, fmt.Errorf("resources of %q: %w", r.Name, err)
+		}
+		delay, err := r.ApplyDelayDuration()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node{
+			name:   r.Name,
+			role:   roleReplica,
+			params: cfg.ReplicaParameters(r),
+			hba:    cfg.ReplicaHBA(r),
+			volume: r.Volume,
+			limits: limits,
+			upstream: &pgsetup.Upstream{
+				// The upstream's headless Service resolves to its pod.
+				Host:            r.Upstream,
+				Port:            postgresPort,
+				ApplicationName: r.Name,
+				SlotName:        pgsetup.SlotName(r.Name),
+				ApplyDelay:      delay,
+				// No provisioning step runs against the upstream, so the
+				// standby creates its own slot before cloning.
+				CreateSlot: true,
+			},
+		})
+	}
+	return nodes, nil
+}
+
+// meta returns the metadata of an object of the cluster named name.
+func meta(cfg *config.Config, name string, labels map[string]string) ObjectMeta {
+	return ObjectMeta{Name: name, Namespace: cfg.Kubernetes.Namespace, Labels: labels}
+}
+
+// nodeLabels returns the labels of a node's objects and pods.
+func nodeLabels(cfg *config.Config, n node) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":    "postgres",
+		"app.kubernetes.io/part-of": "pg-telemetry-lab",
+		labelCluster:                cfg.ClusterName(),
+		labelRole:                   n.role,
+		labelNode:                   n.name,
+	}
+}
+
+// selector returns the labels selecting a node's pod.
+func selector(cfg *config.Config, n node) map[string]string {
+	return map[string]string{labelCluster: cfg.ClusterName(), labelNode: n.name}
+}
+
+// nodeConfigMap holds the node's full postgresql.conf and pg_hba.conf.
+func nodeConfigMap(cfg *config.Config, n node) ConfigMap {
+	return ConfigMap{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata:   meta(cfg, n.name+"-config", nodeLabels(cfg, n)),
+		Data: map[string]string{
+			"postgresql.conf": postgresqlConf(cfg, n),
+			"pg_hba.conf":     hbaConf(n),
+		},
+	}
+}
+
+// postgresqlConf renders the server flags the other providers pass as -c
+// options as a configuration file.
+func postgresqlConf(cfg *config.Config, n node) string {
+	var b strings.Builder
+	b.WriteString("# Rendered by pg-telemetry-lab; unset settings keep their defaults.\n")
+	b.WriteString("listen_addresses = '*'\n")
+	fmt.Fprintf(&b, "hba_file = '%s/pg_hba.conf'\n", configDir)
+	args := pgsetup.ServerArgs(cfg.ReplicationMode(), n.params)
+	for i := 1; i < len(args); i += 2 {
+		name, value, _ := strings.Cut(args[i], "=")
+		fmt.Fprintf(&b, "%s = %s\n", name, pgsetup.QuoteLiteral(value))
+	}
+	return b.String()
+}
+
+// hbaConf renders the node's pg_hba.conf: the image's local trust entries,
+// the managed block and the image's password rule for everything else.
+func hbaConf(n node) string {
+	lines := []string{
+		"local all all trust",
+		"local replication all trust",
+		pgsetup.HBABlockBegin,
+		pgsetup.ReplicationHBARule(),
+	}
+	lines = append(lines, n.hba...)
+	lines = append(lines, pgsetup.HBABlockEnd, "host all all all scram-sha-256")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// initdbConfigMap holds the script the image runs once when it initializes
+// the primary: it creates the replication role and applies the synchronous
+// settings through ALTER SYSTEM, so the first start does not wait for
+// standbys that cannot exist yet.
+func initdbConfigMap(cfg *config.Config) ConfigMap {
+	// psql variables are not expanded in -c or inside DO blocks, so the
+	// password goes through format() and \gexec.
+	sql := []string{fmt.Sprintf(`SELECT format('CREATE ROLE %%I WITH REPLICATION LOGIN PASSWORD %%L', %s, :'pw') \gexec`,
+		pgsetup.QuoteLiteral(pgsetup.ReplicationUser))}
+	for _, stmt := range pgsetup.SynchronousSettingsSQL(cfg.SynchronousStandbyNames(), cfg.Postgres.Replication.SynchronousCommit) {
+		sql = append(sql, stmt+";")
+	}
+	script := strings.Join([]string{
+		"#!/bin/sh",
+		"set -e",
+		`psql -v ON_ERROR_STOP=1 -v pw="$POSTGRES_PASSWORD" --username "$POSTGRES_USER" --dbname postgres <<'SQL'`,
+		strings.Join(sql, "\n"),
+		"SQL",
+	}, "\n") + "\n"
+
+	primary := cfg.Postgres.Primary.HostName
+	return ConfigMap{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata: meta(cfg, primary+"-initdb", map[string]string{
+			labelCluster: cfg.ClusterName(),
+			labelNode:    primary,
+		}),
+		Data: map[string]string{"10-replication.sh": script},
+	}
+}
+
+// nodeService is the node's headless Service. It governs the StatefulSet
+// and gives standbys a stable name for their upstream.
+func nodeService(cfg *config.Config, n node) Service {
+	return Service{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Metadata:   meta(cfg, n.name, nodeLabels(cfg, n)),
+		Spec: ServiceSpec{
+			ClusterIP: "None",
+			Selector:  selector(cfg, n),
+			Ports:     []ServicePort{{Name: "postgres", Port: postgresPort, TargetPort: postgresPort}},
+		},
+	}
+}
+
+// nodeStatefulSet runs the node as a single-pod StatefulSet. Standbys use
+// the same bootstrap script as the container providers.
+func nodeStatefulSet(cfg *config.Config, n node, replicated bool) (StatefulSet, error) {
+	secret := cfg.Kubernetes.SecretName
+	if secret == "" {
+		secret = cfg.ClusterName() + "-postgres"
+	}
+	password := &EnvVarSource{SecretKeyRef: &SecretKeySelector{Name: secret, Key: passwordKey}}
+	serverArgs := []string{"-c", "config_file=" + configDir + "/postgresql.conf"}
+
+	container := Container{
+		Name:  "postgres",
+		Image: cfg.Postgres.Image,
+		// A volume root may hold lost+found, which initdb refuses, so the
+		// data directory is one level down.
+		Env:   []EnvVar{{Name: "PGDATA", Value: pgsetup.DataDir + "/pgdata"}},
+		Ports: []ContainerPort{{Name: "postgres", ContainerPort: postgresPort}},
+		ReadinessProbe: &Probe{
+			Exec: ExecAction{Command: []string{"pg_isready", "-h", "127.0.0.1", "-p", strconv.Itoa(postgresPort),
+				"-U", cfg.Postgres.Primary.User}},
+			PeriodSeconds: 5,
+		},
+		VolumeMounts: []VolumeMount{
+			{Name: "data", MountPath: pgsetup.DataDir},
+			{Name: "config", MountPath: configDir},
+		},
+	}
+	volumes := []Volume{{Name: "config", ConfigMap: &ConfigMapVolumeSource{Name: n.name + "-config"}}}
+
+	if n.upstream == nil {
+		container.Args = append([]string{"postgres"}, serverArgs...)
+		container.Env = append(container.Env,
+			EnvVar{Name: "POSTGRES_USER", Value: cfg.Postgres.Primary.User},
+			EnvVar{Name: "POSTGRES_DB", Value: cfg.Postgres.Primary.Database},
+			EnvVar{Name: "POSTGRES_PASSWORD", ValueFrom: password},
+		)
+		if replicated {
+			container.VolumeMounts = append(container.VolumeMounts, VolumeMount{Name: "initdb", MountPath: initdbDir})
+			volumes = append(volumes, Volume{Name: "initdb", ConfigMap: &ConfigMapVolumeSource{Name: n.name + "-initdb"}})
+		}
+	} else {
+		container.Command = []string{"sh", "-c", pgsetup.StandbyEntrypointScript(*n.upstream, serverArgs)}
+		// pg_receivewal, pg_basebackup and primary_conninfo authenticate
+		// as the replication role.
+		container.Env = append(container.Env, EnvVar{Name: "PGPASSWORD", ValueFrom: password})
+	}
+
+	if res := resourceRequirements(n.limits); res != nil {
+		container.Resources = res
+	}
+	if n.limits.ShmSizeBytes > 0 {
+		// Kubernetes has no shm size setting; a memory-backed emptyDir
+		// over /dev/shm does the same.
+		container.VolumeMounts = append(container.VolumeMounts, VolumeMount{Name: "shm", MountPath: "/dev/shm"})
+		volumes = append(volumes, Volume{Name: "shm", EmptyDir: &EmptyDirVolumeSource{
+			Medium: "Memory", SizeLimit: strconv.FormatInt(n.limits.ShmSizeBytes, 10)}})
+	}
+
+	spec := StatefulSetSpec{
+		ServiceName: n.name,
+		Replicas:    1,
+		Selector:    LabelSelector{MatchLabels: selector(cfg, n)},
+		Template: PodTemplate{
+			Metadata: ObjectMeta{Labels: nodeLabels(cfg, n)},
+			Spec:     PodSpec{Containers: []Container{container}},
+		},
+	}
+	if n.volume != "" {
+		// The volume name only matters to Docker; here it turns into a claim.
+		size := cfg.Kubernetes.StorageSize
+		if size == "" {
+			size = defaultStorageSize
+		}
+		spec.VolumeClaimTemplates = []PersistentVolumeClaim{{
+			Metadata: ObjectMeta{Name: "data"},
+			Spec: PVCSpec{
+				AccessModes:      []string{"ReadWriteOnce"},
+				StorageClassName: cfg.Kubernetes.StorageClass,
+				Resources:        ResourceRequirements{Requests: map[string]string{"storage": size}},
+			},
+		}}
+	} else {
+		volumes = append(volumes, Volume{Name: "data", EmptyDir: &EmptyDirVolumeSource{}})
+	}
+	spec.Template.Spec.Volumes = volumes
+
+	return StatefulSet{
+		APIVersion: "apps/v1",
+		Kind:       "StatefulSet",
+		Metadata:   meta(cfg, n.name, nodeLabels(cfg, n)),
+		Spec:       spec,
+	}, nil
+}
+
+// resourceRequirements turns resource limits into requests and limits of
+// the same size, so benchmark pods get the Guaranteed QoS class. It returns
+// nil when nothing is limited.
+func resourceRequirements(l config.ResourceLimits) *ResourceRequirements {
+	q := map[string]string{}
+	if l.NanoCPUs > 0 {
+		q["cpu"] = fmt.Sprintf("%dm", l.NanoCPUs/1e6)
+	}
+	if l.MemoryBytes > 0 {
+		q["memory"] = strconv.FormatInt(l.MemoryBytes, 10)
+	}
+	if len(q) == 0 {
+		return nil
+	}
+	return &ResourceRequirements{Requests: q, Limits: q}
+}
+
+// encode writes objects as one multi-document YAML file.
+func encode(objects []any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, obj := range objects {
+		if err := enc.Encode(obj); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,66 @@
+package k8s
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestRender renders testdata/<name>.config.yaml and compares every file
+// against testdata/<name>.golden.yaml. Run with -update after an intended
+// change to the output.
+func TestRender(t *testing.T) {
+	for _, name := range []string{"primary-only", "cascading", "apply-delay", "volume"} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := config.Load(filepath.Join("testdata", name+".config.yaml"))
+			if err != nil {
+				t.Fatalf("loading config: %v", err)
+			}
+			files, err := Render(cfg)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+
+			// One golden file per case: the rendered files in order, each
+			// under a comment naming it.
+			var got bytes.Buffer
+			for _, f := range files {
+				got.WriteString("# " + f.Name + "\n")
+				got.Write(f.Content)
+			}
+
+			golden := filepath.Join("testdata", name+".golden.yaml")
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("rendered output differs from %s; run go test ./internal/provider/k8s -update and review the diff\n--- got ---\n%s", golden, got.String())
+			}
+		})
+	}
+}
+
+// TestRenderRejectsLogical checks that only physical replication renders.
+func TestRenderRejectsLogical(t *testing.T) {
+	cfg, err := config.Load(filepath.Join("testdata", "primary-only.config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Postgres.Replication.Mode = config.ReplicationLogical
+	if _, err := Render(cfg); err == nil {
+		t.Error("Render accepted logical replication")
+	}
+}
@@ -0,0 +1,125 @@
+// Package k8s is a provider that runs nothing: it renders the configured
+// topology as Kubernetes manifests (a ConfigMap, headless Service and
+// StatefulSet per node) for kubectl apply.
+package k8s
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+)
+
+// indexFile lists the files the last render wrote to the output directory,
+// so the next render and destroy only remove those.
+const indexFile = ".pg-telemetry-lab-manifests"
+
+var _ provider.PostgresProvider = (*ManifestProvider)(nil)
+
+// ManifestProvider writes the manifests of a cluster to a directory.
+type ManifestProvider struct {
+	outDir string
+}
+
+// NewManifestProvider returns a provider rendering into outDir.
+func NewManifestProvider(outDir string) *ManifestProvider {
+	return &ManifestProvider{outDir: outDir}
+}
+
+// ProvisionPostgres renders cfg into the output directory, replacing the
+// files of a previous render (a replica removed from the config loses its
+// file). The cluster itself is created by applying the directory.
+func (mp *ManifestProvider) ProvisionPostgres(cfg *config.Config) error {
+	files, err := Render(cfg)
+	if err != nil {
+		return err
+	}
+	if err := mp.removeRendered(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(mp.outDir, 0755); err != nil {
+		return fmt.Errorf("creating output directory: %w", err)
+	}
+
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(mp.outDir, f.Name)
+		fmt.Printf("Writing %s\n", path)
+		if err := os.WriteFile(path, f.Content, 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+		names = append(names, f.Name)
+	}
+	index := strings.Join(names, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(mp.outDir, indexFile), []byte(index), 0644); err != nil {
+		return fmt.Errorf("writing manifest index: %w", err)
+	}
+	return nil
+}
+
+// DestroyPostgres removes the rendered files, and the output directory if
+// that leaves it empty. Objects already applied to a cluster are not
+// touched; delete them with kubectl.
+func (mp *ManifestProvider) DestroyPostgres() error {
+	if err := mp.removeRendered(); err != nil {
+		return err
+	}
+	_ = os.Remove(mp.outDir) // only succeeds when empty
+	return nil
+}
+
+// removeRendered removes the files listed in the index, then the index.
+func (mp *ManifestProvider) removeRendered() error {
+	f, err := os.Open(filepath.Join(mp.outDir, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading manifest index: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		// Only plain file names are ever written to the index.
+		if name == "" || name != filepath.Base(name) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(mp.outDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing %s: %w", name, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading manifest index: %w", err)
+	}
+	return os.Remove(filepath.Join(mp.outDir, indexFile))
+}
+
+// Status is not supported: the provider only writes files, and the
+// cluster they describe runs wherever they were applied. Use kubectl.
+func (mp *ManifestProvider) Status(ctx context.Context) (*provider.ClusterStatus, error) {
+	return nil, fmt.Errorf("rendered manifests have no status, inspect the applied objects with kubectl: %w", provider.ErrNotSupported)
+}
+
+// Endpoints is not supported; inside the Kubernetes cluster each node is
+// reachable through its headless Service on port 5432.
+func (mp *ManifestProvider) Endpoints(ctx context.Context) ([]provider.Endpoint, error) {
+	return nil, fmt.Errorf("rendered manifests have no endpoints, each node is reachable through its Service: %w", provider.ErrNotSupported)
+}
+
+// Stop is not supported; scale the StatefulSets to zero with kubectl.
+func (mp *ManifestProvider) Stop(ctx context.Context) error {
+	return fmt.Errorf("stopping rendered manifests, scale the StatefulSets with kubectl: %w", provider.ErrNotSupported)
+}
+
+// Start is not supported; scale the StatefulSets back to one with kubectl.
+func (mp *ManifestProvider) Start(ctx context.Context) error {
+	return fmt.Errorf("starting rendered manifests, scale the StatefulSets with kubectl: %w", provider.ErrNotSupported)
+}
@@ -0,0 +1,145 @@
+package k8s
+
+// The subset of the Kubernetes API objects the manifests use. Field order
+// follows the usual kubectl layout, which yaml.v3 preserves.
+
+type ObjectMeta struct {
+	Name      string            `yaml:"name,omitempty"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+type ConfigMap struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   ObjectMeta        `yaml:"metadata"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type Service struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Metadata   ObjectMeta  `yaml:"metadata"`
+	Spec       ServiceSpec `yaml:"spec"`
+}
+
+type ServiceSpec struct {
+	// ClusterIP "None" makes the Service headless: its DNS name resolves
+	// to the pod, which is what a StatefulSet's governing Service needs.
+	ClusterIP string            `yaml:"clusterIP"`
+	Selector  map[string]string `yaml:"selector"`
+	Ports     []ServicePort     `yaml:"ports"`
+}
+
+type ServicePort struct {
+	Name       string `yaml:"name"`
+	Port       int    `yaml:"port"`
+	TargetPort int    `yaml:"targetPort"`
+}
+
+type StatefulSet struct {
+	APIVersion string          `yaml:"apiVersion"`
+	Kind       string          `yaml:"kind"`
+	Metadata   ObjectMeta      `yaml:"metadata"`
+	Spec       StatefulSetSpec `yaml:"spec"`
+}
+
+type StatefulSetSpec struct {
+	ServiceName          string                  `yaml:"serviceName"`
+	Replicas             int                     `yaml:"replicas"`
+	Selector             LabelSelector           `yaml:"selector"`
+	Template             PodTemplate             `yaml:"template"`
+	VolumeClaimTemplates []PersistentVolumeClaim `yaml:"volumeClaimTemplates,omitempty"`
+}
+
+type LabelSelector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+type PodTemplate struct {
+	Metadata ObjectMeta `yaml:"metadata"`
+	Spec     PodSpec    `yaml:"spec"`
+}
+
+type PodSpec struct {
+	Containers []Container `yaml:"containers"`
+	Volumes    []Volume    `yaml:"volumes,omitempty"`
+}
+
+type Container struct {
+	Name           string                `yaml:"name"`
+	Image          string                `yaml:"image"`
+	Command        []string              `yaml:"command,omitempty"`
+	Args           []string              `yaml:"args,omitempty"`
+	Env            []EnvVar              `yaml:"env,omitempty"`
+	Ports          []ContainerPort       `yaml:"ports,omitempty"`
+	ReadinessProbe *Probe                `yaml:"readinessProbe,omitempty"`
+	Resources      *ResourceRequirements `yaml:"resources,omitempty"`
+	VolumeMounts   []VolumeMount         `yaml:"volumeMounts,omitempty"`
+}
+
+type EnvVar struct {
+	Name      string        `yaml:"name"`
+	Value     string        `yaml:"value,omitempty"`
+	ValueFrom *EnvVarSource `yaml:"valueFrom,omitempty"`
+}
+
+type EnvVarSource struct {
+	SecretKeyRef *SecretKeySelector `yaml:"secretKeyRef,omitempty"`
+}
+
+type SecretKeySelector struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+type ContainerPort struct {
+	Name          string `yaml:"name"`
+	ContainerPort int    `yaml:"containerPort"`
+}
+
+type Probe struct {
+	Exec                ExecAction `yaml:"exec"`
+	InitialDelaySeconds int        `yaml:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       int        `yaml:"periodSeconds,omitempty"`
+}
+
+type ExecAction struct {
+	Command []string `yaml:"command"`
+}
+
+type ResourceRequirements struct {
+	Requests map[string]string `yaml:"requests,omitempty"`
+	Limits   map[string]string `yaml:"limits,omitempty"`
+}
+
+type VolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+}
+
+type Volume struct {
+	Name      string                 `yaml:"name"`
+	ConfigMap *ConfigMapVolumeSource `yaml:"configMap,omitempty"`
+	EmptyDir  *EmptyDirVolumeSource  `yaml:"emptyDir,omitempty"`
+}
+
+type ConfigMapVolumeSource struct {
+	Name string `yaml:"name"`
+}
+
+type EmptyDirVolumeSource struct {
+	Medium    string `yaml:"medium,omitempty"`
+	SizeLimit string `yaml:"sizeLimit,omitempty"`
+}
+
+type PersistentVolumeClaim struct {
+	Metadata ObjectMeta `yaml:"metadata"`
+	Spec     PVCSpec    `yaml:"spec"`
+}
+
+type PVCSpec struct {
+	AccessModes      []string             `yaml:"accessModes"`
+	StorageClassName string               `yaml:"storageClassName,omitempty"`
+	Resources        ResourceRequirements `yaml:"resources"`
+}
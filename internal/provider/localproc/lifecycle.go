@@ -0,0 +1,138 @@
+package localproc
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+)
+
+// Status reports the nodes recorded in the state, with the role of each
+// running node read from the server.
+func (lp *LocalProcProvider) Status(ctx context.Context) (*provider.ClusterStatus, error) {
+	state, err := LoadState(lp.cluster)
+	if err != nil {
+		return nil, err
+	}
+	t := pgTools{binDir: state.BinDir}
+	status := &provider.ClusterStatus{Cluster: lp.cluster}
+	if state.Failed() {
+		status.Error = state.Error
+	}
+	for _, name := range append([]string{state.Primary}, state.Replicas...) {
+		node := provider.NodeStatus{Name: name, Role: provider.RoleUnknown, State: provider.StateStopped}
+		n, ok := state.Nodes[name]
+		if !ok || !hasData(n.DataDir) {
+			node.State = provider.StateMissing
+			status.Nodes = append(status.Nodes, node)
+			continue
+		}
+		running, err := t.running(ctx, n)
+		if err != nil {
+			return nil, fmt.Errorf("checking %q: %w", name, err)
+		}
+		if running {
+			node.State = provider.StateRunning
+			if out, err := t.psqlQuery(ctx, n.Port, state.User, "SELECT pg_is_in_recovery()"); err == nil {
+				node.Role = provider.RolePrimary
+				if out == "t" {
+					node.Role = provider.RoleReplica
+				}
+			}
+		}
+		status.Nodes = append(status.Nodes, node)
+	}
+	return status, nil
+}
+
+// Endpoints returns the nodes recorded in the state. Servers listen on
+// 127.0.0.1 only, which is both inside and outside for host processes.
+func (lp *LocalProcProvider) Endpoints(ctx context.Context) ([]provider.Endpoint, error) {
+	state, err := LoadState(lp.cluster)
+	if err != nil {
+		return nil, err
+	}
+	if state.Failed() {
+		return nil, fmt.Errorf("cluster provisioning failed (%s); run destroy localproc and provision again", state.Error)
+	}
+	endpoints := make([]provider.Endpoint, 0, len(state.Replicas)+1)
+	add := func(name, role string) {
+		var port int
+		if n, ok := state.Nodes[name]; ok {
+			port = n.Port
+		}
+		endpoints = append(endpoints, provider.Endpoint{
+			Node: name, Role: role,
+			Host: "127.0.0.1", Port: port,
+			ExternalHost: "127.0.0.1", ExternalPort: port,
+		})
+	}
+	add(state.Primary, provider.RolePrimary)
+	for _, name := range state.Replicas {
+		add(name, provider.RoleReplica)
+	}
+	return endpoints, nil
+}
+
+// Stop shuts the replicas down, downstream ones first, then the primary.
+// Data directories are kept.
+func (lp *LocalProcProvider) Stop(ctx context.Context) error {
+	state, err := LoadState(lp.cluster)
+	if err != nil {
+		return err
+	}
+	t := pgTools{binDir: state.BinDir}
+	order := slices.Clone(state.Replicas)
+	slices.Reverse(order)
+	order = append(order, state.Primary)
+
+	var errs []string
+	for _, name := range order {
+		if n, ok := state.Nodes[name]; ok {
+			if err := t.stop(ctx, name, n); err != nil {
+				errs = append(errs, fmt.Sprintf("stopping %q: %v", name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors occurred while stopping the cluster: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Start starts the primary, then the replicas, upstreams first, with the
+// flags each was last started with, and records their new PIDs. Nodes
+// already running are left alone.
+func (lp *LocalProcProvider) Start(ctx context.Context) error {
+	state, err := LoadState(lp.cluster)
+	if err != nil {
+		return err
+	}
+	t := pgTools{binDir: state.BinDir}
+	for _, name := range append([]string{state.Primary}, state.Replicas...) {
+		n, ok := state.Nodes[name]
+		if !ok {
+			continue
+		}
+		running, err := t.running(ctx, n)
+		if err != nil {
+			return fmt.Errorf("checking %q: %w", name, err)
+		}
+		if running {
+			continue
+		}
+		if len(n.Args) == 0 {
+			return fmt.Errorf("no server flags recorded for %q; run destroy localproc and provision again", name)
+		}
+		if err := t.start(ctx, name, n, n.Args, config.DefaultReadinessTimeout); err != nil {
+			return err
+		}
+	}
+	if err := SaveState(lp.cluster, *state); err != nil {
+		return fmt.Errorf("saving localproc state: %w", err)
+	}
+	return nil
+}
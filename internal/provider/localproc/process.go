@@ -0,0 +1,222 @@
+package localproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
+)
+
+// pgctlNotRunning is the exit code of `pg_ctl status` when no server runs
+// in the data directory (4 means there is no data directory at all).
+const pgctlNotRunning = 3
+
+// streamPollInterval is the delay between checks of a standby's WAL receiver.
+const streamPollInterval = time.Second
+
+// pgTools runs the Postgres client and server binaries from binDir, or from
+// PATH when it is empty.
+type pgTools struct {
+	binDir string
+}
+
+// run runs a Postgres binary with env added to the environment and returns
+// its combined output. Failures include the output, which is where the
+// binaries explain what went wrong.
+func (t pgTools) run(ctx context.Context, env []string, name string, args ...string) (string, error) {
+	bin := name
+	if t.binDir != "" {
+		bin = filepath.Join(t.binDir, name)
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Env = append(os.Environ(), env...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(out.String()))
+	}
+	return out.String(), nil
+}
+
+// initdb creates a data directory whose superuser is user. Local socket
+// connections are trusted; TCP connections need a password.
+func (t pgTools) initdb(ctx context.Context, dir, user, password string) error {
+	pwfile, err := os.CreateTemp("", "pgtl-pwfile-*")
+	if err != nil {
+		return fmt.Errorf("creating password file: %w", err)
+	}
+	defer os.Remove(pwfile.Name())
+	if _, err := pwfile.WriteString(password + "\n"); err != nil {
+		pwfile.Close()
+		return fmt.Errorf("writing password file: %w", err)
+	}
+	if err := pwfile.Close(); err != nil {
+		return fmt.Errorf("writing password file: %w", err)
+	}
+
+	fmt.Printf("Initializing data directory %s\n", dir)
+	_, err = t.run(ctx, nil, "initdb", "-D", dir, "-U", user, "--pwfile", pwfile.Name(),
+		"--auth-local=trust", "--auth-host=scram-sha-256")
+	return err
+}
+
+// baseBackup clones up into dir and configures dir as its standby, with the
+// same script lines the container providers run in their entrypoint.
+func (t pgTools) baseBackup(ctx context.Context, dir string, up pgsetup.Upstream) error {
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return err
+	}
+	lines := []string{"set -e", pgsetup.BaseBackupCommand(up, `"$PGDATA"`)}
+	lines = append(lines, pgsetup.StandbyConfigLines(up)...)
+	script := strings.Join(lines, "\n")
+	if t.binDir != "" {
+		script = "PATH=" + pgsetup.ShellQuote(t.binDir) + ":$PATH\n" + script
+	}
+
+	fmt.Printf("Cloning %s:%d into %s with pg_basebackup\n", up.Host, up.Port, dir)
+	_, err = t.run(ctx, []string{"PGDATA=" + dir, "PGPASSWORD=" + pw}, "sh", "-c", script)
+	return err
+}
+
+// start starts the server of node with args and waits until it accepts
+// connections, then records its PID and args.
+func (t pgTools) start(ctx context.Context, name string, node *Node, args []string, timeout time.Duration) error {
+	fmt.Printf("Starting %s on port %d (data %s, log %s)\n", name, node.Port, node.DataDir, node.LogFile)
+	_, err := t.run(ctx, nil, "pg_ctl", "start", "-D", node.DataDir, "-l", node.LogFile,
+		"-w", "-t", strconv.Itoa(int(timeout.Seconds())), "-o", pgsetup.ShellJoin(args))
+	if err != nil {
+		return notReadyError(name, node, err)
+	}
+	pid, err := readPID(node.DataDir)
+	if err != nil {
+		return err
+	}
+	node.PID = pid
+	node.Args = args
+	return nil
+}
+
+// stop shuts the server of node down with the fast mode. A server that is
+// not running, or a data directory that is gone, is not an error.
+func (t pgTools) stop(ctx context.Context, name string, node *Node) error {
+	running, err := t.running(ctx, node)
+	if err != nil || !running {
+		return err
+	}
+	fmt.Printf("Stopping %s (pid %d)\n", name, node.PID)
+	_, err = t.run(ctx, nil, "pg_ctl", "stop", "-D", node.DataDir, "-m", "fast", "-w")
+	return err
+}
+
+// running reports whether a server runs in node's data directory.
+func (t pgTools) running(ctx context.Context, node *Node) (bool, error) {
+	_, err := t.run(ctx, nil, "pg_ctl", "status", "-D", node.DataDir)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= pgctlNotRunning {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// psqlQuery runs sql on the server listening on port as user in the
+// maintenance database and returns the unaligned, tuples-only output.
+func (t pgTools) psqlQuery(ctx context.Context, port int, user, sql string) (string, error) {
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return "", err
+	}
+	out, err := t.run(ctx, []string{"PGPASSWORD=" + pw}, "psql", "-X", "-v", "ON_ERROR_STOP=1", "-At",
+		"-h", "127.0.0.1", "-p", strconv.Itoa(port), "-U", user, "-d", "postgres", "-c", sql)
+	if err != nil {
+		return "", fmt.Errorf("running psql on port %d: %w", port, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// psqlExec runs a statement for its side effects. Only the description is
+// printed, since statements may embed passwords.
+func (t pgTools) psqlExec(ctx context.Context, name string, port int, user, description, sql string) error {
+	fmt.Printf("Running SQL on %s: %s\n", name, description)
+	_, err := t.psqlQuery(ctx, port, user, sql)
+	return err
+}
+
+// writeHBARules replaces the managed block of node's pg_hba.conf with
+// rules and reloads the server configuration.
+func (t pgTools) writeHBARules(ctx context.Context, name string, node *Node, user string, rules []string) error {
+	fmt.Printf("Updating pg_hba.conf on %s (%d managed rules)\n", name, len(rules))
+	if _, err := t.run(ctx, []string{"PGDATA=" + node.DataDir}, "sh", "-c", pgsetup.HBAScript(rules)); err != nil {
+		return fmt.Errorf("writing pg_hba.conf on %q: %w", name, err)
+	}
+	return t.psqlExec(ctx, name, node.Port, user, "reload configuration", "SELECT pg_reload_conf()")
+}
+
+// waitForStreaming waits until the standby's WAL receiver is streaming from
+// its upstream.
+func (t pgTools) waitForStreaming(ctx context.Context, name string, node *Node, user string, timeout time.Duration) error {
+	fmt.Printf("Waiting for %s to stream WAL...\n", name)
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := t.psqlQuery(ctx, node.Port, user, "SELECT coalesce(max(status), 'stopped') FROM pg_stat_wal_receiver")
+		if err != nil {
+			status = err.Error()
+		}
+		if status == "streaming" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return notReadyError(name, node, fmt.Errorf("not streaming after %s: WAL receiver is %s", timeout, status))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(streamPollInterval):
+		}
+	}
+}
+
+// readPID returns the postmaster PID from the first line of postmaster.pid.
+func readPID(dataDir string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, "postmaster.pid"))
+	if err != nil {
+		return 0, fmt.Errorf("reading postmaster.pid: %w", err)
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		return 0, fmt.Errorf("parsing postmaster.pid: %w", err)
+	}
+	return pid, nil
+}
+
+// readyLogLines is how many lines of a node's server log are included when
+// it never becomes ready.
+const readyLogLines = 20
+
+// notReadyError wraps a readiness failure of node with the end of its
+// server log, which usually explains why it never came up.
+func notReadyError(name string, node *Node, reason error) error {
+	logs := "(no log output)"
+	if data, err := os.ReadFile(node.LogFile); err == nil {
+		lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+		if len(lines) > readyLogLines {
+			lines = lines[len(lines)-readyLogLines:]
+		}
+		if len(lines) > 0 && lines[0] != "" {
+			logs = strings.Join(lines, "\n")
+		}
+	}
+	return fmt.Errorf("node %q never became ready: %v\nlast %d lines of %s:\n%s",
+		name, reason, readyLogLines, node.LogFile, logs)
+}
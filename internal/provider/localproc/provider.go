@@ -0,0 +1,301 @@
+// Package localproc provisions the Postgres cluster as host processes: each
+// node gets its own data directory, created with initdb or pg_basebackup,
+// and is started with pg_ctl on its configured port. Nothing is
+// virtualized, so benchmarks measure the host directly.
+package localproc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
+)
+
+var _ provider.PostgresProvider = (*LocalProcProvider)(nil)
+
+type LocalProcProvider struct {
+	// cluster selects the state file.
+	cluster string
+}
+
+// NewLocalProcProvider returns a provider managing the named cluster. An
+// empty cluster means config.DefaultCluster.
+func NewLocalProcProvider(cluster string) *LocalProcProvider {
+	if cluster == "" {
+		cluster = config.DefaultCluster
+	}
+	return &LocalProcProvider{cluster: cluster}
+}
+
+// ProvisionPostgres initializes and starts the primary and physical
+// standbys as host processes listening on 127.0.0.1. A data directory that
+// already exists (kept by destroy) is started as-is instead of
+// being initialized or cloned again.
+//
+// Docker-specific settings (network, image, volumes, resources) do not
+// apply. A failed run is recorded in the state so destroy can stop what
+// was started.
+func (lp *LocalProcProvider) ProvisionPostgres(cfg *config.Config) error {
+	ctx := context.Background()
+	if cfg.ClusterName() != lp.cluster {
+		return fmt.Errorf("config is for cluster %q but the provider manages %q", cfg.ClusterName(), lp.cluster)
+	}
+	if cfg.ReplicationMode() != config.ReplicationPhysical {
+		return fmt.Errorf("the localproc target supports %q replication only, got %q",
+			config.ReplicationPhysical, cfg.ReplicationMode())
+	}
+	if os.Geteuid() == 0 {
+		return fmt.Errorf("the localproc target cannot run as root: initdb and postgres refuse to")
+	}
+
+	// Upstreams must be running before the replicas cloned from them.
+	nodes, err := cfg.OrderedReplicaNodes()
+	if err != nil {
+		return fmt.Errorf("ordering replicas: %w", err)
+	}
+
+	if _, err := LoadState(lp.cluster); err == nil {
+		return fmt.Errorf("cluster %q is already provisioned as host processes; run destroy localproc first", lp.cluster)
+	} else if !errors.Is(err, ErrNoState) {
+		return fmt.Errorf("loading localproc state: %w", err)
+	}
+
+	root := cfg.LocalProc.DataDir
+	if root == "" {
+		root = DefaultDataDir
+	}
+	// pg_ctl resolves paths against its own working directory.
+	if root, err = filepath.Abs(root); err != nil {
+		return fmt.Errorf("resolving localproc.data_dir: %w", err)
+	}
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return fmt.Errorf("creating data directory root: %w", err)
+	}
+
+	state := State{
+		Cluster:  lp.cluster,
+		BinDir:   cfg.LocalProc.BinDir,
+		User:     cfg.Postgres.Primary.User,
+		Database: cfg.Postgres.Primary.Database,
+		Primary:  cfg.Postgres.Primary.HostName,
+		Nodes:    make(map[string]*Node, len(nodes)+1),
+	}
+	state.Nodes[state.Primary] = newNode(root, state.Primary, cfg.Postgres.Primary.Port)
+	for _, node := range nodes {
+		state.Nodes[node.Name] = newNode(root, node.Name, node.Port)
+	}
+
+	if err := lp.provision(ctx, cfg, nodes, &state); err != nil {
+		state.Status = StatusFailed
+		state.Error = err.Error()
+		if saveErr := SaveState(lp.cluster, state); saveErr != nil {
+			return fmt.Errorf("%w (saving partial localproc state: %v)", err, saveErr)
+		}
+		return fmt.Errorf("%w; run destroy localproc to stop the partially provisioned cluster", err)
+	}
+
+	state.Status = StatusReady
+	if err := SaveState(lp.cluster, state); err != nil {
+		return fmt.Errorf("saving localproc state: %w", err)
+	}
+	return nil
+}
+
+// newNode returns the paths of a node under root.
+func newNode(root, name string, port int) *Node {
+	return &Node{
+		DataDir: filepath.Join(root, name),
+		LogFile: filepath.Join(root, name+".log"),
+		Port:    port,
+	}
+}
+
+// provision initializes and starts every node, recording each in state as
+// soon as it may be running.
+func (lp *LocalProcProvider) provision(ctx context.Context, cfg *config.Config, nodes []config.ReplicaNode, state *State) error {
+	t := pgTools{binDir: cfg.LocalProc.BinDir}
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return err
+	}
+	timeout := cfg.ReadinessTimeout()
+	user := state.User
+
+	primary := state.Nodes[state.Primary]
+	if !hasData(primary.DataDir) {
+		if err := t.initdb(ctx, primary.DataDir, user, pw); err != nil {
+			return fmt.Errorf("initializing the primary: %w", err)
+		}
+	}
+	if err := t.start(ctx, state.Primary, primary, nodeArgs(cfg, cfg.PrimaryParameters(), primary.Port), timeout); err != nil {
+		return fmt.Errorf("starting the primary: %w", err)
+	}
+	// The superuser connects to "postgres"; create the configured database
+	// the way the image's POSTGRES_DB does.
+	if err := lp.ensureDatabase(ctx, t, state); err != nil {
+		return err
+	}
+
+	rules := cfg.PrimaryHBA()
+	if len(nodes) > 0 {
+		if err := t.psqlExec(ctx, state.Primary, primary.Port, user,
+			"create replication role "+pgsetup.ReplicationUser, pgsetup.ReplicationRoleSQL(pw)); err != nil {
+			return fmt.Errorf("preparing primary for replication: %w", err)
+		}
+		rules = append([]string{pgsetup.ReplicationHBARule()}, rules...)
+		state.ReplicationSlots = make(map[string]string, len(nodes))
+		state.Upstreams = make(map[string]string, len(nodes))
+	}
+	if err := t.writeHBARules(ctx, state.Primary, primary, user, rules); err != nil {
+		return fmt.Errorf("configuring pg_hba.conf on the primary: %w", err)
+	}
+
+	for _, node := range nodes {
+		// Recorded up front: the slot and process may exist even if the
+		// replica never becomes ready.
+		state.Replicas = append(state.Replicas, node.Name)
+		state.ReplicationSlots[node.Name] = pgsetup.SlotName(node.Name)
+		state.Upstreams[node.Name] = node.Upstream
+		if err := lp.runReplica(ctx, t, cfg, node, state); err != nil {
+			return fmt.Errorf("running replica %q: %w", node.Name, err)
+		}
+	}
+
+	if len(nodes) > 0 {
+		stmts := pgsetup.SynchronousSettingsSQL(cfg.SynchronousStandbyNames(), cfg.Postgres.Replication.SynchronousCommit)
+		for _, stmt := range append(stmts, "SELECT pg_reload_conf()") {
+			if err := t.psqlExec(ctx, state.Primary, primary.Port, user, stmt, stmt); err != nil {
+				return fmt.Errorf("configuring synchronous replication: %w", err)
+			}
+		}
+	}
+	for _, name := range state.Replicas {
+		if err := t.waitForStreaming(ctx, name, state.Nodes[name], user, timeout); err != nil {
+			return fmt.Errorf("waiting for cluster readiness: %w", err)
+		}
+	}
+	return nil
+}
+
+// runReplica clones node from its upstream, unless its data directory
+// already exists, and starts it as a streaming standby.
+func (lp *LocalProcProvider) runReplica(ctx context.Context, t pgTools, cfg *config.Config, node config.ReplicaNode, state *State) error {
+	delay, err := node.ApplyDelayDuration()
+	if err != nil {
+		return err
+	}
+	upstreamNode := state.Nodes[node.Upstream]
+	up := pgsetup.Upstream{
+		Host:            "127.0.0.1",
+		Port:            upstreamNode.Port,
+		ApplicationName: node.Name,
+		SlotName:        pgsetup.SlotName(node.Name),
+		ApplyDelay:      delay,
+	}
+
+	// The slot must exist before pg_basebackup -S uses it.
+	if err := t.psqlExec(ctx, node.Upstream, upstreamNode.Port, state.User,
+		"create replication slot "+up.SlotName, pgsetup.CreatePhysicalSlotSQL(up.SlotName)); err != nil {
+		return err
+	}
+
+	n := state.Nodes[node.Name]
+	if !hasData(n.DataDir) {
+		if err := t.baseBackup(ctx, n.DataDir, up); err != nil {
+			return err
+		}
+	}
+	if err := t.start(ctx, node.Name, n, nodeArgs(cfg, cfg.ReplicaParameters(node), n.Port), cfg.ReadinessTimeout()); err != nil {
+		return err
+	}
+	// The clone carries the primary's pg_hba.conf; replace its managed block
+	// with the rules for this node.
+	rules := append([]string{pgsetup.ReplicationHBARule()}, cfg.ReplicaHBA(node)...)
+	return t.writeHBARules(ctx, node.Name, n, state.User, rules)
+}
+
+// ensureDatabase creates the configured database on the primary if missing.
+func (lp *LocalProcProvider) ensureDatabase(ctx context.Context, t pgTools, state *State) error {
+	port := state.PrimaryPort()
+	n, err := t.psqlQuery(ctx, port, state.User,
+		"SELECT count(*) FROM pg_database WHERE datname = "+pgsetup.QuoteLiteral(state.Database))
+	if err != nil {
+		return fmt.Errorf("checking database %q: %w", state.Database, err)
+	}
+	if n != "0" {
+		return nil
+	}
+	return t.psqlExec(ctx, state.Primary, port, state.User, "create database "+state.Database,
+		"CREATE DATABASE "+pgsetup.QuoteIdent(state.Database))
+}
+
+// nodeArgs returns the server flags of a node listening on port. Servers
+// only listen on 127.0.0.1 and create no unix socket, so nodes do not
+// depend on a writable socket directory such as /var/run/postgresql.
+func nodeArgs(cfg *config.Config, params map[string]string, port int) []string {
+	args := pgsetup.ServerArgs(cfg.ReplicationMode(), params)
+	return append(args,
+		"-c", fmt.Sprintf("port=%d", port),
+		"-c", "listen_addresses=127.0.0.1",
+		"-c", "unix_socket_directories=",
+	)
+}
+
+// hasData reports whether dir holds an initialized data directory.
+func hasData(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "PG_VERSION"))
+	return err == nil && info.Size() > 0
+}
+
+// DestroyPostgres stops every node, keeping its data directory.
+func (lp *LocalProcProvider) DestroyPostgres() error {
+	return lp.DestroyPostgresWithOptions(provider.DestroyOptions{})
+}
+
+// DestroyPostgresWithOptions stops the replicas, then the primary. The data
+// directories stay, so the next provision starts every node from its
+// existing data; Purge removes them and the logs as well. Data is kept or
+// removed for all nodes together, so the replication slots on the primary
+// always match the standbys that remain.
+func (lp *LocalProcProvider) DestroyPostgresWithOptions(opts provider.DestroyOptions) error {
+	ctx := context.Background()
+	state, err := LoadState(lp.cluster)
+	if err != nil {
+		return err
+	}
+	t := pgTools{binDir: state.BinDir}
+
+	var errs []string
+	order := append(append([]string{}, state.Replicas...), state.Primary)
+	for _, name := range order {
+		node, ok := state.Nodes[name]
+		if !ok {
+			continue
+		}
+		if err := t.stop(ctx, name, node); err != nil {
+			errs = append(errs, fmt.Sprintf("stopping %q: %v", name, err))
+			continue
+		}
+		if !opts.Purge {
+			fmt.Printf("Keeping data of %s in %s\n", name, node.DataDir)
+			continue
+		}
+		fmt.Printf("Removing data directory %s\n", node.DataDir)
+		if err := os.RemoveAll(node.DataDir); err != nil {
+			errs = append(errs, fmt.Sprintf("removing data of %q: %v", name, err))
+		}
+		_ = os.Remove(node.LogFile)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors occurred while destroying the cluster: %s", strings.Join(errs, "; "))
+	}
+	_ = os.Remove(StatePath(lp.cluster))
+	return nil
+}
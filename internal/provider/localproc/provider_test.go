@@ -0,0 +1,69 @@
+package localproc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+)
+
+// provisionFake records a one-node cluster whose pg_ctl reports the server
+// as not running, and returns the node's data directory.
+func provisionFake(t *testing.T) string {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	binDir, err := filepath.Abs("bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(binDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	pgctl := "#!/bin/sh\nexit 3\n"
+	if err := os.WriteFile(filepath.Join(binDir, "pg_ctl"), []byte(pgctl), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dataDir, _ := filepath.Abs("data/pg-primary")
+	if err := os.MkdirAll(dataDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("16\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	state := State{
+		Cluster: "default",
+		BinDir:  binDir,
+		Primary: "pg-primary",
+		Nodes:   map[string]*Node{"pg-primary": {DataDir: dataDir, Port: 5432}},
+		Status:  StatusReady,
+	}
+	if err := SaveState("default", state); err != nil {
+		t.Fatal(err)
+	}
+	return dataDir
+}
+
+func TestDestroyKeepsData(t *testing.T) {
+	dataDir := provisionFake(t)
+	if err := NewLocalProcProvider("default").DestroyPostgres(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "PG_VERSION")); err != nil {
+		t.Errorf("data directory not kept: %v", err)
+	}
+	if _, err := os.Stat(StatePath("default")); !os.IsNotExist(err) {
+		t.Errorf("state file not removed: %v", err)
+	}
+}
+
+func TestDestroyPurge(t *testing.T) {
+	dataDir := provisionFake(t)
+	lp := NewLocalProcProvider("default")
+	if err := lp.DestroyPostgresWithOptions(provider.DestroyOptions{Purge: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dataDir); !os.IsNotExist(err) {
+		t.Errorf("data directory not removed: %v", err)
+	}
+}
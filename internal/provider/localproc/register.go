@@ -0,0 +1,43 @@
+package localproc
+
+import (
+	"fmt"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/benchmark"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+)
+
+// Target is the CLI name of this provider.
+const Target = "localproc"
+
+func init() {
+	provider.Register(provider.Target{
+		Name:         Target,
+		Title:        "Host-process",
+		Description:  "Run nodes as host processes with initdb/pg_ctl",
+		Capabilities: []string{provider.CapProvision, provider.CapDestroy, provider.CapBenchmark, provider.CapStatus},
+		New: func(cluster string) (provider.PostgresProvider, error) {
+			return NewLocalProcProvider(cluster), nil
+		},
+		Destroy: func(cluster string, opts provider.DestroyOptions) (string, error) {
+			lp := NewLocalProcProvider(cluster)
+			if err := lp.DestroyPostgresWithOptions(opts); err != nil {
+				return "", err
+			}
+			if opts.Purge {
+				return "servers stopped, data directories removed", nil
+			}
+			return "servers stopped, data directories kept", nil
+		},
+	})
+
+	// pgbench runs on the host too; benchmark.resources does not apply.
+	benchmark.RegisterRunner(Target, func(cfg *config.Config, cluster string) (benchmark.Runner, error) {
+		state, err := LoadState(cluster)
+		if err != nil {
+			return nil, fmt.Errorf("loading localproc state: %w", err)
+		}
+		return benchmark.NewLocalRunner(state.BinDir), nil
+	})
+}
@@ -0,0 +1,118 @@
+package localproc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StateDir holds one state file per cluster run as host processes.
+const StateDir = ".telemetry/localproc"
+
+// DefaultDataDir holds the node data directories when localproc.data_dir
+// is not set.
+const DefaultDataDir = ".telemetry/localproc/data"
+
+// StatePath returns the state file of cluster.
+func StatePath(cluster string) string {
+	return filepath.Join(StateDir, cluster+".json")
+}
+
+// Provisioning outcomes recorded in State.Status.
+const (
+	StatusReady  = "ready"
+	StatusFailed = "failed"
+)
+
+// ErrNoState is returned by LoadState when nothing was provisioned yet.
+var ErrNoState = errors.New("no localproc state found, provision first")
+
+// Node is a Postgres server running as a host process.
+type Node struct {
+	// DataDir is the absolute path of the node's data directory.
+	DataDir string `json:"data_dir"`
+	// LogFile receives the server output (pg_ctl -l).
+	LogFile string `json:"log_file"`
+	Port    int    `json:"port"`
+	// PID is the postmaster process ID when the node was last started.
+	PID int `json:"pid,omitempty"`
+	// Args are the server flags the node was last started with, so it can
+	// be started again without the config.
+	Args []string `json:"args,omitempty"`
+}
+
+// State records a cluster provisioned as host processes.
+type State struct {
+	Cluster  string `json:"cluster"`
+	BinDir   string `json:"bin_dir,omitempty"`
+	User     string `json:"user"`
+	Database string `json:"database"`
+
+	Primary  string   `json:"primary"`
+	Replicas []string `json:"replicas"`
+
+	// Nodes maps node name to its process and paths.
+	Nodes map[string]*Node `json:"nodes"`
+
+	// ReplicationSlots maps standby name to the physical replication slot
+	// reserved for it on its upstream.
+	ReplicationSlots map[string]string `json:"replication_slots,omitempty"`
+
+	// Upstreams maps standby name to the node it streams from.
+	Upstreams map[string]string `json:"upstreams,omitempty"`
+
+	CreatedAt string `json:"created_at"`
+
+	// Status is StatusFailed when provisioning stopped part way; Error
+	// holds the reason.
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Failed reports whether the last provisioning run did not complete.
+func (s *State) Failed() bool {
+	return s.Status == StatusFailed
+}
+
+// PrimaryPort returns the port of the primary.
+func (s *State) PrimaryPort() int {
+	if n, ok := s.Nodes[s.Primary]; ok {
+		return n.Port
+	}
+	return 0
+}
+
+// SaveState writes the state of cluster to disk.
+func SaveState(cluster string, state State) error {
+	path := StatePath(cluster)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+	if state.CreatedAt == "" {
+		state.CreatedAt = time.Now().Format(time.RFC3339)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadState reads the state of cluster from disk.
+func LoadState(cluster string) (*State, error) {
+	data, err := os.ReadFile(StatePath(cluster))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoState
+		}
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("unmarshal state: %w", err)
+	}
+	return &state, nil
+}
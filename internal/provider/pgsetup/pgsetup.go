@@ -0,0 +1,254 @@
+// Package pgsetup holds the Postgres setup steps shared by the providers:
+// server flags, standby bootstrap scripts, pg_hba.conf rules and the SQL
+// for roles and slots. Most of it only builds commands and statements; the
+// steps that talk to a running server go through a Runtime, which the
+// container providers implement.
+package pgsetup
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+)
+
+const (
+	// DataDir is the data directory used inside every Postgres container.
+	// It is set explicitly so standby bootstrap scripts and later data
+	// operations do not depend on the image's default PGDATA.
+	DataDir = "/var/lib/postgresql/data"
+
+	// ReplicationUser is the role standbys use to stream WAL from the primary.
+	ReplicationUser = "replicator"
+
+	// HBABlockBegin and HBABlockEnd delimit the pg_hba.conf rules managed by
+	// this tool, so they can be rewritten without touching the image defaults.
+	HBABlockBegin = "# BEGIN pg-telemetry-lab"
+	HBABlockEnd   = "# END pg-telemetry-lab"
+)
+
+// Upstream describes the server a standby streams WAL from.
+type Upstream struct {
+	Host            string
+	Port            int
+	ApplicationName string
+	// SlotName is the physical replication slot on the upstream reserved for
+	// this standby.
+	SlotName string
+	// ApplyDelay is recovery_min_apply_delay; zero disables it.
+	ApplyDelay time.Duration
+	// CreateSlot makes the standby create SlotName on the upstream itself
+	// before cloning, for platforms where no provisioning step can do it
+	// beforehand (Kubernetes).
+	CreateSlot bool
+}
+
+// ServerArgs returns the postgres server flags shared by the primary and
+// standbys. Standbys need the same (or larger) max_wal_senders as the
+// primary, and using identical settings lets any node be promoted later.
+func ServerArgs(mode string, params map[string]string) []string {
+	walLevel := "replica"
+	if mode == config.ReplicationLogical {
+		walLevel = "logical"
+	}
+	args := []string{
+		"-c", "wal_level=" + walLevel,
+		"-c", "max_wal_senders=10",
+		"-c", "max_replication_slots=10",
+		"-c", "hot_standby=on",
+		// pg_rewind needs hint-bit changes WAL-logged to rejoin a former primary.
+		"-c", "wal_log_hints=on",
+	}
+	// User parameters come last so they win over the defaults above; the
+	// config rejects the ones replication depends on.
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		args = append(args, "-c", name+"="+params[name])
+	}
+	return args
+}
+
+// ReplicationRoleSQL creates the replication role, or resets its password
+// if it already exists.
+func ReplicationRoleSQL(password string) string {
+	return fmt.Sprintf(`DO $$
+BEGIN
+  IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = %[1]s) THEN
+    CREATE ROLE %[2]s WITH REPLICATION LOGIN PASSWORD %[3]s;
+  ELSE
+    ALTER ROLE %[2]s WITH REPLICATION LOGIN PASSWORD %[3]s;
+  END IF;
+END
+$$;`, QuoteLiteral(ReplicationUser), QuoteIdent(ReplicationUser), QuoteLiteral(password))
+}
+
+// SynchronousSettingsSQL returns the ALTER SYSTEM statements setting
+// synchronous_standby_names and synchronous_commit on the primary. Empty
+// values reset the setting to its default. The server must reload its
+// configuration afterwards.
+func SynchronousSettingsSQL(standbyNames, commit string) []string {
+	settings := []struct{ name, value string }{
+		{"synchronous_standby_names", standbyNames},
+		{"synchronous_commit", commit},
+	}
+	stmts := make([]string, len(settings))
+	for i, s := range settings {
+		stmts[i] = fmt.Sprintf("ALTER SYSTEM RESET %s", s.name)
+		if s.value != "" {
+			stmts[i] = fmt.Sprintf("ALTER SYSTEM SET %s = %s", s.name, QuoteLiteral(s.value))
+		}
+	}
+	return stmts
+}
+
+// ReplicationHBARule lets the replication role connect from any host on
+// the network. Standbys keep it so cascading standbys can stream from them.
+func ReplicationHBARule() string {
+	return fmt.Sprintf("host replication %s all scram-sha-256", ReplicationUser)
+}
+
+// HBAScript returns a shell script that replaces the managed block at the
+// top of $PGDATA/pg_hba.conf with rules. Rules are prepended because
+// pg_hba.conf is first-match and the image appends a catch-all entry. The
+// server must reload its configuration afterwards.
+func HBAScript(rules []string) string {
+	block := append([]string{HBABlockBegin}, rules...)
+	block = append(block, HBABlockEnd)
+
+	return strings.Join([]string{
+		"set -e",
+		`f="$PGDATA/pg_hba.conf"`,
+		fmt.Sprintf(`{ printf '%%s\n' %s; sed '/^%s$/,/^%s$/d' "$f"; } > "$f.tmp"`,
+			ShellJoin(block), HBABlockBegin, HBABlockEnd),
+		`mv "$f.tmp" "$f"`,
+	}, "\n")
+}
+
+// StandbyEntrypointScript returns the shell script used as the entrypoint of
+// a standby container. On first start it clones the upstream with
+// pg_basebackup and configures streaming replication; on later starts the
+// existing data directory is reused as-is. args are the server flags.
+func StandbyEntrypointScript(up Upstream, args []string) string {
+	lines := []string{
+		"set -e",
+		`if [ ! -s "$PGDATA/PG_VERSION" ]; then`,
+	}
+	if up.CreateSlot {
+		lines = append(lines, "  "+CreateSlotCommand(up))
+	}
+	lines = append(lines, "  "+BaseBackupCommand(up, `"$PGDATA"`))
+	for _, line := range StandbyConfigLines(up) {
+		lines = append(lines, "  "+line)
+	}
+	lines = append(lines,
+		"fi",
+		// The stock entrypoint fixes ownership of the cloned files and drops
+		// privileges before starting the server.
+		"exec "+ShellJoin(append([]string{"docker-entrypoint.sh", "postgres"}, args...)),
+	)
+	return strings.Join(lines, "\n")
+}
+
+// BaseBackupCommand returns the pg_basebackup command line cloning up into
+// dir (already shell-quoted), streaming WAL through the standby's slot.
+func BaseBackupCommand(up Upstream, dir string) string {
+	return fmt.Sprintf(`pg_basebackup -h %s -p %d -U %s -D %s -X stream -c fast -S %s`,
+		up.Host, up.Port, ReplicationUser, dir, up.SlotName)
+}
+
+// CreateSlotCommand returns a pg_receivewal command line creating the
+// standby's physical slot on up over a replication connection, so it works
+// on standbys (cascading) and is a no-op if the slot exists. The slot
+// reserves WAL immediately, like CreatePhysicalSlotSQL.
+func CreateSlotCommand(up Upstream) string {
+	return fmt.Sprintf(`pg_receivewal -h %s -p %d -U %s --slot %s --create-slot --if-not-exists`,
+		up.Host, up.Port, ReplicationUser, up.SlotName)
+}
+
+// StandbyConfigLines returns shell commands that turn the data directory in
+// $PGDATA into a standby of up: any previous primary_* settings are removed
+// from postgresql.auto.conf, the new ones appended and standby.signal created.
+// Rejoining a former primary passes no ApplyDelay, which also clears any
+// delay it had as a standby before.
+func StandbyConfigLines(up Upstream) []string {
+	// $PGPASSWORD is expanded by the shell that runs the lines, so the
+	// password never appears in a command line we print.
+	conninfo := PrimaryConninfo(up, "$PGPASSWORD")
+
+	lines := []string{
+		`touch "$PGDATA/postgresql.auto.conf"`,
+		`sed -i '/^primary_conninfo\|^primary_slot_name\|^recovery_min_apply_delay/d' "$PGDATA/postgresql.auto.conf"`,
+		fmt.Sprintf(`echo "primary_conninfo = '%s'" >> "$PGDATA/postgresql.auto.conf"`, conninfo),
+		fmt.Sprintf(`echo "primary_slot_name = '%s'" >> "$PGDATA/postgresql.auto.conf"`, up.SlotName),
+	}
+	if up.ApplyDelay > 0 {
+		lines = append(lines, fmt.Sprintf(`echo "recovery_min_apply_delay = '%dms'" >> "$PGDATA/postgresql.auto.conf"`,
+			up.ApplyDelay.Milliseconds()))
+	}
+	return append(lines, `touch "$PGDATA/standby.signal"`)
+}
+
+// PrimaryConninfo builds the primary_conninfo a standby uses to reach up.
+func PrimaryConninfo(up Upstream, password string) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s application_name=%s",
+		up.Host, up.Port, ReplicationUser, password, up.ApplicationName)
+}
+
+// SlotName returns the physical replication slot name for a standby.
+func SlotName(node string) string {
+	return SafeName(node)
+}
+
+// CreatePhysicalSlotSQL creates a physical slot unless it already exists.
+// The slot reserves WAL immediately so nothing is recycled between creating
+// it and the standby connecting.
+func CreatePhysicalSlotSQL(slot string) string {
+	return fmt.Sprintf(`SELECT pg_create_physical_replication_slot(%[1]s, true)
+WHERE NOT EXISTS (SELECT FROM pg_replication_slots WHERE slot_name = %[1]s)`, QuoteLiteral(slot))
+}
+
+// DropSlotSQL drops a physical or logical slot if it exists.
+func DropSlotSQL(slot string) string {
+	return fmt.Sprintf(`SELECT pg_drop_replication_slot(slot_name)
+FROM pg_replication_slots WHERE slot_name = %s`, QuoteLiteral(slot))
+}
+
+// SafeName turns a node name into something usable as an unquoted
+// Postgres identifier (slot, subscription), e.g. "pg-replica-1" -> "pg_replica_1".
+func SafeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// QuoteLiteral quotes s as an SQL string literal.
+func QuoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// QuoteIdent quotes s as an SQL identifier.
+func QuoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// ShellQuote quotes s for use as a single POSIX shell word.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ShellJoin quotes every word and joins them into a shell command line.
+func ShellJoin(words []string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = ShellQuote(w)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,190 @@
+package pgsetup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
+)
+
+// Labels put on every container, network and pod of a cluster, so the
+// container providers can find it without the state file.
+const (
+	LabelCluster = "pg-telemetry-lab.cluster"
+	LabelRole    = "pg-telemetry-lab.role" // role at creation, see RoleXxx
+)
+
+// Node roles as recorded in LabelRole. Labels cannot change after creation,
+// so after a failover the live role is read from the server instead.
+const (
+	RolePrimary    = "primary"
+	RoleReplica    = "replica"
+	RoleSubscriber = "subscriber"
+)
+
+const (
+	// readyPollInterval is the delay between readiness probes.
+	readyPollInterval = time.Second
+
+	// readyLogLines is how many log lines of a node that never became ready
+	// are included in the error.
+	readyLogLines = 20
+)
+
+// Runtime runs commands in the Postgres containers of a cluster. The steps
+// below that talk to a running server only need this from a provider.
+type Runtime interface {
+	// Exec runs cmd in container as user ("" keeps the container default)
+	// and returns its output. A non-zero exit code is an error.
+	Exec(ctx context.Context, container, user string, cmd ...string) (string, error)
+	// Logs returns the last lines of container's output.
+	Logs(ctx context.Context, container string, lines int) (string, error)
+	// ContainerState reports whether container is running and, if not, a
+	// short description of its state for errors.
+	ContainerState(ctx context.Context, container string) (running bool, state string, err error)
+}
+
+// Probe checks one readiness condition. It returns a short reason when the
+// condition does not hold yet.
+type Probe func(ctx context.Context) (ok bool, reason string, err error)
+
+// PsqlQuery runs sql in container as user and returns the unaligned,
+// tuples-only output. An empty database means the "postgres" maintenance
+// database, which always exists.
+func PsqlQuery(ctx context.Context, rt Runtime, container, user, database, sql string) (string, error) {
+	if database == "" {
+		database = "postgres"
+	}
+	out, err := rt.Exec(ctx, container, "",
+		"psql", "-X", "-v", "ON_ERROR_STOP=1", "-At", "-U", user, "-d", database, "-c", sql)
+	if err != nil {
+		return "", fmt.Errorf("running psql on %q: %w", container, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// PsqlExec runs a statement for its side effects. Only the description is
+// printed, since statements may embed passwords.
+func PsqlExec(ctx context.Context, rt Runtime, container, user, database, description, sql string) error {
+	fmt.Printf("Running SQL on %s: %s\n", container, description)
+	_, err := PsqlQuery(ctx, rt, container, user, database, sql)
+	return err
+}
+
+// WriteHBARules replaces the managed block at the top of pg_hba.conf on
+// container with rules and reloads the server configuration.
+func WriteHBARules(ctx context.Context, rt Runtime, container, user string, rules []string) error {
+	fmt.Printf("Updating pg_hba.conf on %s (%d managed rules)\n", container, len(rules))
+	if _, err := rt.Exec(ctx, container, "postgres", "sh", "-c", HBAScript(rules)); err != nil {
+		return fmt.Errorf("writing pg_hba.conf on %q: %w", container, err)
+	}
+	return PsqlExec(ctx, rt, container, user, "", "reload configuration", "SELECT pg_reload_conf()")
+}
+
+// PreparePrimaryForReplication creates the replication role on the primary
+// and allows it to connect for replication through pg_hba.conf, next to the
+// configured primary rules.
+func PreparePrimaryForReplication(ctx context.Context, rt Runtime, cfg *config.Config) error {
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return err
+	}
+	primary, user := cfg.Postgres.Primary.HostName, cfg.Postgres.Primary.User
+	if err := PsqlExec(ctx, rt, primary, user, "", "create replication role "+ReplicationUser, ReplicationRoleSQL(pw)); err != nil {
+		return err
+	}
+	rules := append([]string{ReplicationHBARule()}, cfg.PrimaryHBA()...)
+	return WriteHBARules(ctx, rt, primary, user, rules)
+}
+
+// ApplySynchronousSettings sets synchronous_standby_names and
+// synchronous_commit on the primary through ALTER SYSTEM. Empty values reset
+// the setting to its default. Run it only once the standbys exist, since a
+// primary with synchronous standbys configured blocks commits until they
+// connect.
+func ApplySynchronousSettings(ctx context.Context, rt Runtime, primary, user, standbyNames, commit string) error {
+	for _, stmt := range SynchronousSettingsSQL(standbyNames, commit) {
+		if err := PsqlExec(ctx, rt, primary, user, "", stmt, stmt); err != nil {
+			return err
+		}
+	}
+	return PsqlExec(ctx, rt, primary, user, "", "reload configuration", "SELECT pg_reload_conf()")
+}
+
+// WaitForPostgres waits until the server in container accepts TCP
+// connections. The TCP check matters: during first-time initialization the
+// image runs a temporary server that only listens on the unix socket.
+func WaitForPostgres(ctx context.Context, rt Runtime, container string, timeout time.Duration) error {
+	fmt.Printf("Waiting for %s to accept connections...\n", container)
+	return WaitFor(ctx, rt, container, timeout, func(ctx context.Context) (bool, string, error) {
+		if _, err := rt.Exec(ctx, container, "", "pg_isready", "-q", "-h", "127.0.0.1"); err != nil {
+			return false, "not accepting connections", nil
+		}
+		return true, "", nil
+	})
+}
+
+// WaitForStreaming waits until the standby's WAL receiver is streaming from
+// its upstream.
+func WaitForStreaming(ctx context.Context, rt Runtime, standby, user string, timeout time.Duration) error {
+	fmt.Printf("Waiting for %s to stream WAL...\n", standby)
+	return WaitFor(ctx, rt, standby, timeout, func(ctx context.Context) (bool, string, error) {
+		status, err := PsqlQuery(ctx, rt, standby, user, "", "SELECT coalesce(max(status), 'stopped') FROM pg_stat_wal_receiver")
+		if err != nil {
+			return false, "", err
+		}
+		if status != "streaming" {
+			return false, "WAL receiver is " + status, nil
+		}
+		return true, "", nil
+	})
+}
+
+// WaitFor polls check until it succeeds or timeout expires. It fails early
+// if the container stops running. Errors name the container and include its
+// last log lines, which usually explain why a server never came up.
+func WaitFor(ctx context.Context, rt Runtime, container string, timeout time.Duration, check Probe) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		running, state, err := rt.ContainerState(ctx, container)
+		if err != nil {
+			return fmt.Errorf("node %q: inspecting container: %w", container, err)
+		}
+		if !running {
+			return notReadyError(ctx, rt, container, "container is "+state)
+		}
+
+		ok, reason, err := check(ctx)
+		if err != nil {
+			reason = err.Error()
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return notReadyError(ctx, rt, container, fmt.Sprintf("not ready after %s: %s", timeout, reason))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
+
+// notReadyError builds a readiness failure for container with its last log lines.
+func notReadyError(ctx context.Context, rt Runtime, container, reason string) error {
+	logs, err := rt.Logs(ctx, container, readyLogLines)
+	if err != nil {
+		logs = fmt.Sprintf("(could not read logs: %v)", err)
+	}
+	logs = strings.TrimRight(logs, "\n")
+	if logs == "" {
+		logs = "(no log output)"
+	}
+	return fmt.Errorf("node %q never became ready: %s\nlast %d log lines of %s:\n%s",
+		container, reason, readyLogLines, container, logs)
+}
@@ -0,0 +1,123 @@
+package podmanpg
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+)
+
+// stopTimeoutSeconds gives a busy server time for its shutdown checkpoint.
+const stopTimeoutSeconds = 60
+
+// Status reports the containers recorded in the state, with the role of
+// each running node read from the server.
+func (pp *PodmanPostgresProvider) Status(ctx context.Context) (*provider.ClusterStatus, error) {
+	state, err := LoadState(pp.cluster)
+	if err != nil {
+		return nil, err
+	}
+	status := &provider.ClusterStatus{Cluster: pp.cluster}
+	if state.Failed() {
+		status.Error = state.Error
+	}
+	for _, name := range append([]string{state.PrimaryContainer}, state.ReplicaContainers...) {
+		node := provider.NodeStatus{Name: name, Role: provider.RoleUnknown, State: provider.StateStopped}
+		exists, err := pp.client.Exists(ctx, "container", name)
+		if err != nil {
+			return nil, fmt.Errorf("checking container %q: %w", name, err)
+		}
+		running, err := pp.client.ContainerRunning(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("inspecting container %q: %w", name, err)
+		}
+		switch {
+		case !exists:
+			node.State = provider.StateMissing
+		case running:
+			node.State = provider.StateRunning
+			if out, err := pgsetup.PsqlQuery(ctx, pp.runtime(), name, state.User, "", "SELECT pg_is_in_recovery()"); err == nil {
+				node.Role = provider.RolePrimary
+				if out == "t" {
+					node.Role = provider.RoleReplica
+				}
+			}
+		}
+		status.Nodes = append(status.Nodes, node)
+	}
+	return status, nil
+}
+
+// Endpoints returns the nodes recorded in the state. Nodes share the pod's
+// network namespace and publish their port unchanged, so a node is
+// 127.0.0.1 on the same port from inside the pod and from the host.
+func (pp *PodmanPostgresProvider) Endpoints(ctx context.Context) ([]provider.Endpoint, error) {
+	state, err := LoadState(pp.cluster)
+	if err != nil {
+		return nil, err
+	}
+	if state.Failed() {
+		return nil, fmt.Errorf("cluster provisioning failed (%s); run destroy podman and provision again", state.Error)
+	}
+	endpoints := make([]provider.Endpoint, 0, len(state.ReplicaContainers)+1)
+	add := func(name, role string) {
+		port := state.Ports[name]
+		endpoints = append(endpoints, provider.Endpoint{
+			Node: name, Role: role,
+			Host: "127.0.0.1", Port: port,
+			ExternalHost: "127.0.0.1", ExternalPort: port,
+		})
+	}
+	add(state.PrimaryContainer, provider.RolePrimary)
+	for _, name := range state.ReplicaContainers {
+		add(name, provider.RoleReplica)
+	}
+	return endpoints, nil
+}
+
+// Stop stops the replica containers, downstream ones first, then the
+// primary. The pod, containers and volumes are kept.
+func (pp *PodmanPostgresProvider) Stop(ctx context.Context) error {
+	state, err := LoadState(pp.cluster)
+	if err != nil {
+		return err
+	}
+	order := slices.Clone(state.ReplicaContainers)
+	slices.Reverse(order)
+	order = append(order, state.PrimaryContainer)
+
+	var errs []string
+	for _, name := range order {
+		fmt.Printf("Stopping container %s\n", name)
+		if err := pp.client.StopContainer(ctx, name, stopTimeoutSeconds); err != nil {
+			errs = append(errs, fmt.Sprintf("stopping %q: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors occurred while stopping the pod: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Start starts the primary, then the replicas, upstreams first, waiting
+// until each accepts connections.
+func (pp *PodmanPostgresProvider) Start(ctx context.Context) error {
+	state, err := LoadState(pp.cluster)
+	if err != nil {
+		return err
+	}
+	for _, name := range append([]string{state.PrimaryContainer}, state.ReplicaContainers...) {
+		fmt.Printf("Starting container %s\n", name)
+		if err := pp.client.StartContainer(ctx, name); err != nil {
+			return fmt.Errorf("starting %q: %w", name, err)
+		}
+		if err := pgsetup.WaitForPostgres(ctx, pp.runtime(), name, config.DefaultReadinessTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
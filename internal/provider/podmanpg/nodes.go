@@ -0,0 +1,90 @@
+package podmanpg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/podman"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
+)
+
+// nodeSpec describes a Postgres container to run in the pod.
+type nodeSpec struct {
+	Name  string
+	Pod   string
+	Image string
+	// Port is the port the server listens on inside the pod.
+	Port int
+	Role string
+	// Volume is mounted at the data directory: a named volume or an
+	// absolute host path. Empty leaves data in the container.
+	Volume string
+	Limits config.ResourceLimits
+	Env    []string
+	// Secrets are passed to the container through the podman process
+	// environment, so they never appear on its command line.
+	Secrets    map[string]string
+	Entrypoint string // empty keeps the image entrypoint
+	Cmd        []string
+}
+
+// runNode creates and starts the container described by spec.
+func (pp *PodmanPostgresProvider) runNode(ctx context.Context, spec nodeSpec) error {
+	args := []string{"run", "--detach", "--name", spec.Name, "--pod", spec.Pod,
+		"--label", pgsetup.LabelCluster + "=" + pp.cluster,
+		"--label", pgsetup.LabelRole + "=" + spec.Role,
+		"--env", "PGDATA=" + pgsetup.DataDir,
+		// The server, the image's init-time temporary server, psql and
+		// pg_isready all read PGPORT, which keeps nodes in the shared
+		// network namespace apart.
+		"--env", "PGPORT=" + strconv.Itoa(spec.Port),
+	}
+	for _, e := range spec.Env {
+		args = append(args, "--env", e)
+	}
+	var secrets []string
+	for name, value := range spec.Secrets {
+		args = append(args, "--env", name)
+		secrets = append(secrets, name+"="+value)
+	}
+	if spec.Volume != "" {
+		args = append(args, "--volume", spec.Volume+":"+pgsetup.DataDir)
+	}
+	if spec.Limits.NanoCPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(float64(spec.Limits.NanoCPUs)/1e9, 'f', -1, 64))
+	}
+	if spec.Limits.MemoryBytes > 0 {
+		args = append(args, "--memory", strconv.FormatInt(spec.Limits.MemoryBytes, 10))
+	}
+	if spec.Entrypoint != "" {
+		args = append(args, "--entrypoint", spec.Entrypoint)
+	}
+	args = append(args, spec.Image)
+	args = append(args, spec.Cmd...)
+
+	fmt.Printf("Creating container %s (image %s, pod %s, port %d, env %s)\n",
+		spec.Name, spec.Image, spec.Pod, spec.Port, strings.Join(util.MaskArgs(spec.Env), " "))
+	if _, err := pp.client.Run(ctx, secrets, args...); err != nil {
+		return fmt.Errorf("running container %q: %w", spec.Name, err)
+	}
+	return nil
+}
+
+// podmanRuntime runs the shared setup and readiness steps of pgsetup
+// through the podman CLI.
+type podmanRuntime struct {
+	*podman.Client
+}
+
+func (pp *PodmanPostgresProvider) runtime() pgsetup.Runtime {
+	return podmanRuntime{Client: pp.client}
+}
+
+func (r podmanRuntime) ContainerState(ctx context.Context, container string) (bool, string, error) {
+	running, err := r.ContainerRunning(ctx, container)
+	return running, "not running", err
+}
@@ -0,0 +1,353 @@
+// Package podmanpg provisions the Postgres cluster with rootless Podman.
+// All nodes of a cluster run in one pod and share its network namespace,
+// so each server listens on its own port and nodes reach each other on
+// 127.0.0.1. The pod publishes every node's port on the host.
+package podmanpg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/podman"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider/pgsetup"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/util"
+)
+
+var _ provider.PostgresProvider = (*PodmanPostgresProvider)(nil)
+
+type PodmanPostgresProvider struct {
+	client *podman.Client
+	// cluster selects the state file and the pod label used for discovery.
+	cluster string
+}
+
+// NewPodmanPostgresProvider returns a provider managing the named cluster
+// through client. An empty cluster means config.DefaultCluster.
+func NewPodmanPostgresProvider(client *podman.Client, cluster string) *PodmanPostgresProvider {
+	if cluster == "" {
+		cluster = config.DefaultCluster
+	}
+	return &PodmanPostgresProvider{client: client, cluster: cluster}
+}
+
+// ProvisionPostgres creates the cluster's pod and starts the primary and
+// physical standbys in it. The pod is named after postgres.network, which
+// it replaces. Standbys are seeded from their upstream with pg_basebackup
+// and stream WAL through a replication slot, as with the Docker provider.
+//
+// Unlike the Docker provider this is not a reconcile: ports published by a
+// pod are fixed when it is created, so an existing cluster must be
+// destroyed before provisioning it again. A failed run is recorded in the
+// state so destroy can clean it up.
+func (pp *PodmanPostgresProvider) ProvisionPostgres(cfg *config.Config) error {
+	ctx := context.Background()
+	if cfg.ClusterName() != pp.cluster {
+		return fmt.Errorf("config is for cluster %q but the provider manages %q", cfg.ClusterName(), pp.cluster)
+	}
+	if cfg.ReplicationMode() != config.ReplicationPhysical {
+		return fmt.Errorf("the podman target supports %q replication only, got %q",
+			config.ReplicationPhysical, cfg.ReplicationMode())
+	}
+
+	// Replicas are cloned from their upstream, so order them upstream first.
+	nodes, err := cfg.OrderedReplicaNodes()
+	if err != nil {
+		return fmt.Errorf("ordering replicas: %w", err)
+	}
+
+	if _, err := LoadState(pp.cluster); err == nil {
+		return fmt.Errorf("cluster %q is already provisioned with podman; run destroy podman first", pp.cluster)
+	} else if !errors.Is(err, ErrNoState) {
+		return fmt.Errorf("loading podman state: %w", err)
+	}
+
+	state := State{
+		Cluster:          pp.cluster,
+		Pod:              cfg.Postgres.Network,
+		Image:            cfg.Postgres.Image,
+		User:             cfg.Postgres.Primary.User,
+		Database:         cfg.Postgres.Primary.Database,
+		PrimaryContainer: cfg.Postgres.Primary.HostName,
+		Ports:            map[string]int{cfg.Postgres.Primary.HostName: cfg.Postgres.Primary.Port},
+	}
+	state.setVolume(state.PrimaryContainer, cfg.Postgres.Primary.Volume)
+	for _, node := range nodes {
+		state.Ports[node.Name] = node.Port
+	}
+
+	if err := pp.provision(ctx, cfg, nodes, &state); err != nil {
+		state.Status = StatusFailed
+		state.Error = err.Error()
+		if saveErr := SaveState(pp.cluster, state); saveErr != nil {
+			return fmt.Errorf("%w (saving partial podman state: %v)", err, saveErr)
+		}
+		return fmt.Errorf("%w; run destroy podman to remove the partially provisioned cluster", err)
+	}
+
+	state.Status = StatusReady
+	if err := SaveState(pp.cluster, state); err != nil {
+		return fmt.Errorf("saving podman state: %w", err)
+	}
+	return nil
+}
+
+// provision creates the pod and its nodes, recording every container in
+// state as soon as it may exist.
+func (pp *PodmanPostgresProvider) provision(ctx context.Context, cfg *config.Config, nodes []config.ReplicaNode, state *State) error {
+	if err := pp.createPod(ctx, cfg, nodes); err != nil {
+		return fmt.Errorf("creating pod %q: %w", state.Pod, err)
+	}
+	if err := pp.runPrimary(ctx, cfg); err != nil {
+		return fmt.Errorf("running primary Postgres container: %w", err)
+	}
+	if len(nodes) > 0 {
+		if err := pgsetup.PreparePrimaryForReplication(ctx, pp.runtime(), cfg); err != nil {
+			return fmt.Errorf("preparing primary for replication: %w", err)
+		}
+		state.ReplicationSlots = make(map[string]string, len(nodes))
+		state.Upstreams = make(map[string]string, len(nodes))
+	} else if err := pgsetup.WriteHBARules(ctx, pp.runtime(), state.PrimaryContainer, state.User, cfg.PrimaryHBA()); err != nil {
+		return fmt.Errorf("configuring pg_hba.conf on the primary: %w", err)
+	}
+
+	for _, node := range nodes {
+		// A failed replica can leave its container and slot behind, so
+		// destroy must know about it before it is run.
+		state.ReplicaContainers = append(state.ReplicaContainers, node.Name)
+		state.ReplicationSlots[node.Name] = pgsetup.SlotName(node.Name)
+		state.Upstreams[node.Name] = node.Upstream
+		state.setVolume(node.Name, node.Volume)
+		if err := pp.runReplica(ctx, cfg, node, state.Ports[node.Upstream]); err != nil {
+			return fmt.Errorf("running replica %q Postgres container: %w", node.Name, err)
+		}
+	}
+
+	if len(nodes) > 0 {
+		if err := pgsetup.ApplySynchronousSettings(ctx, pp.runtime(), cfg.Postgres.Primary.HostName, cfg.Postgres.Primary.User,
+			cfg.SynchronousStandbyNames(), cfg.Postgres.Replication.SynchronousCommit); err != nil {
+			return fmt.Errorf("configuring synchronous replication: %w", err)
+		}
+	}
+	// Only report success once every standby streams, so a benchmark
+	// started right after provisioning sees a complete cluster.
+	for _, name := range state.ReplicaContainers {
+		if err := pgsetup.WaitForStreaming(ctx, pp.runtime(), name, state.User, cfg.ReadinessTimeout()); err != nil {
+			return fmt.Errorf("waiting for cluster readiness: %w", err)
+		}
+	}
+	return nil
+}
+
+// createPod creates the pod shared by all nodes, publishing each node's
+// port on the same host port.
+func (pp *PodmanPostgresProvider) createPod(ctx context.Context, cfg *config.Config, nodes []config.ReplicaNode) error {
+	args := []string{"pod", "create", "--name", cfg.Postgres.Network,
+		"--label", pgsetup.LabelCluster + "=" + cfg.ClusterName()}
+
+	ports := []int{cfg.Postgres.Primary.Port}
+	// Containers share the pod's IPC namespace and cannot size /dev/shm
+	// themselves, so the pod gets the largest shm_size of any node.
+	shm, err := cfg.Postgres.Primary.Resources.Limits()
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		ports = append(ports, node.Port)
+		limits, err := cfg.ReplicaResources(node).Limits()
+		if err != nil {
+			return err
+		}
+		shm.ShmSizeBytes = max(shm.ShmSizeBytes, limits.ShmSizeBytes)
+	}
+	for _, port := range ports {
+		args = append(args, "--publish", fmt.Sprintf("%d:%d", port, port))
+	}
+	if shm.ShmSizeBytes > 0 {
+		args = append(args, "--shm-size", strconv.FormatInt(shm.ShmSizeBytes, 10))
+	}
+
+	fmt.Printf("Creating pod %s (ports %v)\n", cfg.Postgres.Network, ports)
+	_, err = pp.client.Run(ctx, nil, args...)
+	return err
+}
+
+func (pp *PodmanPostgresProvider) runPrimary(ctx context.Context, cfg *config.Config) error {
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return err
+	}
+	limits, err := cfg.Postgres.Primary.Resources.Limits()
+	if err != nil {
+		return err
+	}
+
+	spec := nodeSpec{
+		Name:   cfg.Postgres.Primary.HostName,
+		Pod:    cfg.Postgres.Network,
+		Image:  cfg.Postgres.Image,
+		Port:   cfg.Postgres.Primary.Port,
+		Role:   pgsetup.RolePrimary,
+		Volume: cfg.Postgres.Primary.Volume,
+		Limits: limits,
+		Env: []string{
+			"POSTGRES_USER=" + cfg.Postgres.Primary.User,
+			"POSTGRES_DB=" + cfg.Postgres.Primary.Database,
+		},
+		Secrets: map[string]string{"POSTGRES_PASSWORD": pw},
+		// The image entrypoint runs the server with these flags.
+		Cmd: append([]string{"postgres"}, pgsetup.ServerArgs(cfg.ReplicationMode(), cfg.PrimaryParameters())...),
+	}
+	if err := pp.runNode(ctx, spec); err != nil {
+		return err
+	}
+	return pgsetup.WaitForPostgres(ctx, pp.runtime(), spec.Name, cfg.ReadinessTimeout())
+}
+
+// runReplica starts a streaming physical standby of node's upstream, which
+// listens on upstreamPort inside the pod.
+func (pp *PodmanPostgresProvider) runReplica(ctx context.Context, cfg *config.Config, node config.ReplicaNode, upstreamPort int) error {
+	pw, err := util.GetRequiredEnv("PG_PASSWORD")
+	if err != nil {
+		return err
+	}
+	delay, err := node.ApplyDelayDuration()
+	if err != nil {
+		return err
+	}
+	limits, err := cfg.ReplicaResources(node).Limits()
+	if err != nil {
+		return err
+	}
+	upstream := pgsetup.Upstream{
+		Host:            "127.0.0.1",
+		Port:            upstreamPort,
+		ApplicationName: node.Name,
+		SlotName:        pgsetup.SlotName(node.Name),
+		ApplyDelay:      delay,
+	}
+
+	// The slot must exist before pg_basebackup -S uses it.
+	if err := pgsetup.PsqlExec(ctx, pp.runtime(), node.Upstream, cfg.Postgres.Primary.User, "",
+		"create replication slot "+upstream.SlotName, pgsetup.CreatePhysicalSlotSQL(upstream.SlotName)); err != nil {
+		return err
+	}
+
+	args := pgsetup.ServerArgs(cfg.ReplicationMode(), cfg.ReplicaParameters(node))
+	spec := nodeSpec{
+		Name:   node.Name,
+		Pod:    cfg.Postgres.Network,
+		Image:  cfg.Postgres.Image,
+		Port:   node.Port,
+		Role:   pgsetup.RoleReplica,
+		Volume: node.Volume,
+		Limits: limits,
+		// pg_basebackup and primary_conninfo authenticate as the replication role.
+		Secrets:    map[string]string{"PGPASSWORD": pw},
+		Entrypoint: "sh",
+		Cmd:        []string{"-c", pgsetup.StandbyEntrypointScript(upstream, args)},
+	}
+	if err := pp.runNode(ctx, spec); err != nil {
+		return err
+	}
+	if err := pgsetup.WaitForPostgres(ctx, pp.runtime(), node.Name, cfg.ReadinessTimeout()); err != nil {
+		return err
+	}
+	// pg_basebackup copied the upstream's pg_hba.conf along with the data.
+	rules := append([]string{pgsetup.ReplicationHBARule()}, cfg.ReplicaHBA(node)...)
+	return pgsetup.WriteHBARules(ctx, pp.runtime(), node.Name, cfg.Postgres.Primary.User, rules)
+}
+
+// DestroyPostgres removes the cluster's pod and containers, keeping any
+// persistent data volumes.
+func (pp *PodmanPostgresProvider) DestroyPostgres() error {
+	return pp.DestroyPostgresWithOptions(provider.DestroyOptions{})
+}
+
+// DestroyPostgresWithOptions removes the cluster's pod and containers. If
+// the state file is missing, the pod is found by its cluster label.
+//
+// Named data volumes are kept unless Purge is set; host paths are never
+// deleted. When the primary keeps its data, the slots of standbys that lose
+// theirs are dropped first so the primary does not retain WAL for them
+// forever.
+func (pp *PodmanPostgresProvider) DestroyPostgresWithOptions(opts provider.DestroyOptions) error {
+	ctx := context.Background()
+	state, err := LoadState(pp.cluster)
+	if errors.Is(err, ErrNoState) {
+		return pp.destroyByLabel(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("loading podman state: %w", err)
+	}
+
+	var errs []string
+	primaryRunning, _ := pp.client.ContainerRunning(ctx, state.PrimaryContainer)
+	keepPrimaryData := !opts.Purge && state.Volumes[state.PrimaryContainer] != ""
+	for _, replica := range state.ReplicaContainers {
+		fmt.Printf("Removing container %s\n", replica)
+		if err := pp.client.RemoveContainer(ctx, replica); err != nil {
+			errs = append(errs, fmt.Sprintf("removing replica container %q: %v", replica, err))
+		}
+	}
+	for _, replica := range state.ReplicaContainers {
+		slot, ok := state.ReplicationSlots[replica]
+		if !ok || !primaryRunning || !keepPrimaryData || state.Volumes[replica] != "" ||
+			state.Upstreams[replica] != state.PrimaryContainer {
+			continue
+		}
+		if err := pgsetup.PsqlExec(ctx, pp.runtime(), state.PrimaryContainer, state.User, "", "drop replication slot "+slot, pgsetup.DropSlotSQL(slot)); err != nil {
+			errs = append(errs, fmt.Sprintf("dropping replication slot %q: %v", slot, err))
+		}
+	}
+
+	fmt.Printf("Removing pod %s\n", state.Pod)
+	if err := pp.client.RemovePod(ctx, state.Pod); err != nil {
+		errs = append(errs, fmt.Sprintf("removing pod %q: %v", state.Pod, err))
+	}
+	for _, name := range slices.Sorted(maps.Keys(state.Volumes)) {
+		volume := state.Volumes[name]
+		switch {
+		case !opts.Purge:
+			fmt.Printf("Keeping data of %s in %s\n", name, volume)
+		case config.IsHostPath(volume):
+			fmt.Printf("Leaving data in host path %s\n", volume)
+		default:
+			fmt.Printf("Removing volume %s\n", volume)
+			if err := pp.client.RemoveVolume(ctx, volume); err != nil {
+				errs = append(errs, fmt.Sprintf("removing data of %q: %v", name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors occurred while destroying the pod: %s", strings.Join(errs, "; "))
+	}
+	_ = os.Remove(StatePath(pp.cluster))
+	return nil
+}
+
+// destroyByLabel removes the pods labelled with the cluster. Volumes are
+// kept since, without the state file, it is unknown which belong to it.
+func (pp *PodmanPostgresProvider) destroyByLabel(ctx context.Context) error {
+	pods, err := pp.client.ListPods(ctx, pgsetup.LabelCluster+"="+pp.cluster)
+	if err != nil {
+		return fmt.Errorf("listing pods of cluster %q: %w", pp.cluster, err)
+	}
+	if len(pods) == 0 {
+		return ErrNoState
+	}
+	for _, pod := range pods {
+		fmt.Printf("Removing pod %s\n", pod)
+		if err := pp.client.RemovePod(ctx, pod); err != nil {
+			return fmt.Errorf("removing pod %q: %w", pod, err)
+		}
+	}
+	return nil
+}
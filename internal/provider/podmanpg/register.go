@@ -0,0 +1,65 @@
+package podmanpg
+
+import (
+	"fmt"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/benchmark"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/podman"
+	"github.com/elenaochkina/pg-telemetry-lab/internal/provider"
+)
+
+// Target is the CLI name of this provider.
+const Target = "podman"
+
+func init() {
+	provider.Register(provider.Target{
+		Name:         Target,
+		Title:        "Podman",
+		Description:  "Use rootless Podman, all nodes in one pod",
+		Capabilities: []string{provider.CapProvision, provider.CapDestroy, provider.CapBenchmark, provider.CapStatus},
+		New: func(cluster string) (provider.PostgresProvider, error) {
+			return NewFromEnv(cluster)
+		},
+		Destroy: func(cluster string, opts provider.DestroyOptions) (string, error) {
+			pp, err := NewFromEnv(cluster)
+			if err != nil {
+				return "", err
+			}
+			if err := pp.DestroyPostgresWithOptions(opts); err != nil {
+				return "", err
+			}
+			if opts.Purge {
+				return "pod and data volumes removed", nil
+			}
+			return "pod removed, data volumes kept", nil
+		},
+	})
+
+	// pgbench runs inside the pod, next to the servers.
+	benchmark.RegisterRunner(Target, func(cfg *config.Config, cluster string) (benchmark.Runner, error) {
+		client, err := podman.NewClient()
+		if err != nil {
+			return nil, err
+		}
+		state, err := LoadState(cluster)
+		if err != nil {
+			return nil, fmt.Errorf("loading podman state: %w", err)
+		}
+		runner := benchmark.NewPodmanRunner(client, cfg.Postgres.Image, state.Pod)
+		if runner.Limits, err = cfg.Benchmark.Resources.Limits(); err != nil {
+			return nil, fmt.Errorf("benchmark.resources: %w", err)
+		}
+		return runner, nil
+	})
+}
+
+// NewFromEnv returns a provider managing cluster with the podman binary
+// named by PODMAN_BINARY or found on PATH.
+func NewFromEnv(cluster string) (*PodmanPostgresProvider, error) {
+	client, err := podman.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return NewPodmanPostgresProvider(client, cluster), nil
+}
@@ -0,0 +1,116 @@
+package podmanpg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StateDir holds one state file per cluster provisioned with Podman.
+const StateDir = ".telemetry/podman"
+
+// StatePath returns the state file of cluster.
+func StatePath(cluster string) string {
+	return filepath.Join(StateDir, cluster+".json")
+}
+
+// Provisioning outcomes recorded in State.Status.
+const (
+	StatusReady  = "ready"
+	StatusFailed = "failed"
+)
+
+// ErrNoState is returned by LoadState when nothing was provisioned yet.
+var ErrNoState = errors.New("no podman state found, provision first")
+
+// State records a cluster provisioned with Podman.
+type State struct {
+	Cluster  string `json:"cluster"`
+	Pod      string `json:"pod"`
+	Image    string `json:"image"`
+	User     string `json:"user"`
+	Database string `json:"database"`
+
+	PrimaryContainer  string   `json:"primary_container"`
+	ReplicaContainers []string `json:"replica_containers"`
+
+	// Ports maps container name to the port its server listens on. Inside
+	// the pod every node shares one network namespace, so the port is also
+	// how nodes reach each other on 127.0.0.1, and it is published on the
+	// host unchanged.
+	Ports map[string]int `json:"ports"`
+
+	// ReplicationSlots maps standby container name to the physical
+	// replication slot reserved for it on its upstream.
+	ReplicationSlots map[string]string `json:"replication_slots,omitempty"`
+
+	// Upstreams maps standby container name to the container it streams from.
+	Upstreams map[string]string `json:"upstreams,omitempty"`
+
+	// Volumes maps container name to the named volume or host path holding
+	// its data directory.
+	Volumes map[string]string `json:"volumes,omitempty"`
+
+	CreatedAt string `json:"created_at"`
+
+	// Status is StatusFailed when provisioning stopped part way; Error
+	// holds the reason.
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Failed reports whether the last provisioning run did not complete.
+func (s *State) Failed() bool {
+	return s.Status == StatusFailed
+}
+
+// PrimaryPort returns the port of the primary, published on the host.
+func (s *State) PrimaryPort() int {
+	return s.Ports[s.PrimaryContainer]
+}
+
+// setVolume records the data volume of container; an empty volume is a no-op.
+func (s *State) setVolume(container, volume string) {
+	if volume == "" {
+		return
+	}
+	if s.Volumes == nil {
+		s.Volumes = make(map[string]string)
+	}
+	s.Volumes[container] = volume
+}
+
+// SaveState writes the state of cluster to disk.
+func SaveState(cluster string, state State) error {
+	path := StatePath(cluster)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+	if state.CreatedAt == "" {
+		state.CreatedAt = time.Now().Format(time.RFC3339)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadState reads the state of cluster from disk.
+func LoadState(cluster string) (*State, error) {
+	data, err := os.ReadFile(StatePath(cluster))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoState
+		}
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("unmarshal state: %w", err)
+	}
+	return &state, nil
+}
@@ -1,9 +1,103 @@
 package provider
-import ("github.com/elenaochkina/pg-telemetry-lab/internal/config")
 
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/elenaochkina/pg-telemetry-lab/internal/config"
+)
+
+// PostgresProvider creates and runs a Postgres cluster on some platform.
+// Code that only needs to reach or inspect a cluster (benchmarks,
+// telemetry) should go through this interface rather than a provider's
+// own state file.
 type PostgresProvider interface {
 	ProvisionPostgres(cfg *config.Config) error
 	DestroyPostgres() error
+
+	// Status reports every node of the cluster with its live role and
+	// whether it runs.
+	Status(ctx context.Context) (*ClusterStatus, error)
+
+	// Endpoints returns where the primary and replicas accept connections,
+	// primary first. It fails if the last provisioning run did not
+	// complete.
+	Endpoints(ctx context.Context) ([]Endpoint, error)
+
+	// Stop shuts every node down, replicas before their upstreams, keeping
+	// the data. Start brings them back up, primary first, and waits until
+	// each accepts connections.
+	Stop(ctx context.Context) error
+	Start(ctx context.Context) error
+}
+
+// ErrNotSupported is wrapped by providers for operations their platform
+// has no equivalent of.
+var ErrNotSupported = errors.New("not supported by this provider")
+
+// Node roles reported by Status and Endpoints.
+const (
+	RolePrimary = "primary"
+	RoleReplica = "replica"
+	// RoleFenced is a former primary stopped by a failover.
+	RoleFenced = "fenced"
+	// RoleUnknown is reported when the role cannot be determined, e.g.
+	// because the node is not running.
+	RoleUnknown = "-"
+)
+
+// Node states reported by Status.
+const (
+	StateRunning = "running"
+	StateStopped = "stopped"
+	// StatePending is a node being created or deleted.
+	StatePending = "pending"
+	StateFailed  = "failed"
+	// StateMissing is a node the provider's state lists but that no longer
+	// exists.
+	StateMissing = "missing"
+)
+
+// Endpoint is where one node of the cluster accepts connections.
+type Endpoint struct {
+	Node string
+	Role string // RolePrimary or RoleReplica
+
+	// Host and Port reach the node from inside the cluster's network: from
+	// other containers on the Docker network, from inside the pod, or from
+	// the host for host processes. Benchmark containers connect here.
+	Host string
+	Port int
+
+	// ExternalHost and ExternalPort reach the node from the machine running
+	// telemetryctl. ExternalPort is 0 when the node is not reachable from
+	// there, e.g. a container without a published port.
+	ExternalHost string
+	ExternalPort int
 }
 
+// NodeStatus is the state of one node.
+type NodeStatus struct {
+	Name  string
+	Role  string // one of the Role constants
+	State string // one of the State constants
+}
+
+// ClusterStatus is the result of Status.
+type ClusterStatus struct {
+	Cluster string
+	Nodes   []NodeStatus
+	// Error is set when the last provisioning run failed, with the reason.
+	Error string
+}
 
+// PrimaryEndpoint returns the endpoint of the primary among endpoints.
+func PrimaryEndpoint(endpoints []Endpoint) (Endpoint, error) {
+	for _, e := range endpoints {
+		if e.Role == RolePrimary {
+			return e, nil
+		}
+	}
+	return Endpoint{}, fmt.Errorf("no primary endpoint")
+}
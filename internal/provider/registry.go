@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Capabilities a target can declare, named after the CLI commands they
+// enable.
+const (
+	CapProvision  = "provision"
+	CapDestroy    = "destroy"
+	CapBenchmark  = "benchmark"
+	CapStatus     = "status"
+	CapSlots      = "slots"
+	CapLag        = "lag"
+	CapFailover   = "failover"
+	CapSwitchover = "switchover"
+	CapScale      = "scale"
+	// CapRestart covers stop, start and restart of individual nodes.
+	CapRestart = "stop/start/restart"
+	// CapClusters lists every cluster of the target (clusters list).
+	CapClusters = "clusters"
+)
+
+// DestroyOptions are the destroy flags of the CLI. Every target keeps the
+// nodes' persistent data unless Purge is set.
+type DestroyOptions struct {
+	// Purge also removes the data: named volumes or data directories.
+	Purge bool
+}
+
+// Target is a CLI target backed by a provider.
+type Target struct {
+	// Name is what the CLI calls the target, e.g. "local".
+	Name string
+	// Title names the platform in messages, e.g. "Local" in
+	// "Local PostgreSQL cluster provisioned".
+	Title string
+	// Description is shown by the CLI usage.
+	Description string
+	// Capabilities lists the commands the target supports.
+	Capabilities []string
+
+	// New returns the provider managing cluster.
+	New func(cluster string) (PostgresProvider, error)
+
+	// Destroy removes cluster honouring opts and returns what happened to
+	// the data, e.g. "containers removed, data volumes kept". Nil means
+	// New(cluster).DestroyPostgres() with no summary.
+	Destroy func(cluster string, opts DestroyOptions) (string, error)
+}
+
+// Supports reports whether t declares capability.
+func (t Target) Supports(capability string) bool {
+	return slices.Contains(t.Capabilities, capability)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Target)
+)
+
+// Register makes a target available under t.Name. Provider packages call
+// it from init; registering a name twice panics.
+func Register(t Target) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[t.Name]; dup {
+		panic("provider: target " + t.Name + " registered twice")
+	}
+	registry[t.Name] = t
+}
+
+// Targets returns the registered targets sorted by name.
+func Targets() []Target {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	targets := make([]Target, 0, len(registry))
+	for _, t := range registry {
+		targets = append(targets, t)
+	}
+	slices.SortFunc(targets, func(a, b Target) int { return strings.Compare(a.Name, b.Name) })
+	return targets
+}
+
+// Lookup returns the target called name if it supports capability.
+func Lookup(name, capability string) (Target, error) {
+	registryMu.RLock()
+	t, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok || !t.Supports(capability) {
+		var names []string
+		for _, t := range Targets() {
+			if t.Supports(capability) {
+				names = append(names, fmt.Sprintf("%q", t.Name))
+			}
+		}
+		if !ok {
+			return Target{}, fmt.Errorf("unsupported target %q (targets supporting %s: %s)", name, capability, strings.Join(names, ", "))
+		}
+		return Target{}, fmt.Errorf("target %q does not support %s (targets that do: %s)", name, capability, strings.Join(names, ", "))
+	}
+	return t, nil
+}
@@ -55,3 +55,17 @@ func ContainsWhitespace(s string) bool {
     }
     return false
 }
+
+// FormatBytes renders a byte count using binary units, e.g. 1536 -> "1.5 KiB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}